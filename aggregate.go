@@ -0,0 +1,372 @@
+package mgc
+
+import (
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"regexp"
+	"strings"
+)
+
+var aggregateFunctions = map[string]string{
+	"COUNT": "$sum",
+	"SUM":   "$sum",
+	"AVG":   "$avg",
+	"MIN":   "$min",
+	"MAX":   "$max",
+}
+
+var aggregateExpr = regexp.MustCompile(`(?i)\b(COUNT|SUM|AVG|MIN|MAX)\s*\(\s*([^)]*)\)`)
+var aliasExpr = regexp.MustCompile(`(?i)^(.+?)\s+(?:AS\s+)?([\w]+)$`)
+var nonWordExpr = regexp.MustCompile(`\W+`)
+var distinctExpr = regexp.MustCompile(`(?i)^\s*SELECT\s+DISTINCT\s+`)
+
+//selectItem represents select list item
+type selectItem struct {
+	Expression string
+	Alias      string
+	Function   string //upper case aggregate function name if item is an aggregate
+	Argument   string //aggregate function argument
+	Distinct   bool   //aggregate function uses DISTINCT argument
+}
+
+//Name returns item output name
+func (i *selectItem) Name() string {
+	if i.Alias != "" {
+		return i.Alias
+	}
+	if i.Function == "" {
+		return i.Expression
+	}
+	var name = strings.ToLower(i.Function)
+	if i.Distinct {
+		name += "_distinct"
+	}
+	if argument := strings.Trim(nonWordExpr.ReplaceAllString(i.Argument, "_"), "_"); argument != "" {
+		name += "_" + argument
+	}
+	return name
+}
+
+//Accumulator returns $group accumulator expression
+func (i *selectItem) Accumulator() interface{} {
+	var argument = strings.TrimSpace(i.Argument)
+	if i.Distinct {
+		return bson.M{"$addToSet": "$" + argument}
+	}
+	if i.Function == "COUNT" {
+		if argument == "*" || argument == "1" {
+			return bson.M{"$sum": 1}
+		}
+		return bson.M{"$sum": bson.M{"$cond": []interface{}{bson.M{"$gt": []interface{}{"$" + argument, nil}}, 1, 0}}}
+	}
+	return bson.M{aggregateFunctions[i.Function]: "$" + argument}
+}
+
+//Projection returns $project stage expression for accumulated value, distinct values set is reduced with aggregate function
+func (i *selectItem) Projection() interface{} {
+	if !i.Distinct {
+		return 1
+	}
+	var values = "$" + i.Name()
+	switch i.Function {
+	case "COUNT":
+		return bson.M{"$size": values}
+	case "SUM", "AVG", "MIN", "MAX":
+		return bson.M{aggregateFunctions[i.Function]: values}
+	}
+	return 1
+}
+
+func newSelectItem(expression string) *selectItem {
+	var result = &selectItem{Expression: strings.TrimSpace(expression)}
+	if matched := aliasExpr.FindStringSubmatch(result.Expression); len(matched) == 3 {
+		result.Expression = strings.TrimSpace(matched[1])
+		result.Alias = matched[2]
+	}
+	if matched := aggregateExpr.FindStringSubmatch(result.Expression); len(matched) == 3 && matched[0] == result.Expression {
+		result.Function = strings.ToUpper(matched[1])
+		result.Argument = strings.TrimSpace(matched[2])
+		if argument := strings.Fields(result.Argument); len(argument) == 2 && strings.ToUpper(argument[0]) == "DISTINCT" {
+			result.Distinct = true
+			result.Argument = argument[1]
+		}
+	}
+	return result
+}
+
+//aggregateQuery represents aggregate query
+type aggregateQuery struct {
+	Items    []*selectItem
+	GroupBy  []string
+	Having   string
+	Distinct bool          //SELECT DISTINCT query, selected columns are used as group by columns
+	hidden   []*selectItem //aggregates used only by HAVING
+}
+
+func (q *aggregateQuery) allItems() []*selectItem {
+	var result = make([]*selectItem, 0, len(q.Items)+len(q.hidden))
+	result = append(result, q.Items...)
+	return append(result, q.hidden...)
+}
+
+//isAggregate returns true if any select item is an aggregate function
+func isAggregate(items []*selectItem) bool {
+	for _, item := range items {
+		if item.Function != "" {
+			return true
+		}
+	}
+	return false
+}
+
+//splitTopLevel splits text by separator outside quotes and parenthesis
+func splitTopLevel(text string, separator byte) []string {
+	var result = make([]string, 0)
+	var start = 0
+	scanUnquoted(text, func(i, depth int) bool {
+		if depth == 0 && text[i] == separator {
+			result = append(result, text[start:i])
+			start = i + 1
+		}
+		return true
+	})
+	return append(result, text[start:])
+}
+
+//parseSelectItems returns select list items and FROM keyword index
+func parseSelectItems(SQL string) ([]*selectItem, int, error) {
+	var selectIndex = indexKeyword(SQL, "SELECT")
+	var fromIndex = indexKeyword(SQL, "FROM")
+	if selectIndex == -1 || fromIndex == -1 || fromIndex < selectIndex {
+		return nil, 0, fmt.Errorf("invalid query: %v", SQL)
+	}
+	var items = make([]*selectItem, 0)
+	var selectList = SQL[selectIndex+len("SELECT") : fromIndex]
+	for _, expression := range splitTopLevel(selectList, ',') {
+		if strings.TrimSpace(expression) == "" {
+			return nil, 0, fmt.Errorf("invalid select list: %v", SQL)
+		}
+		items = append(items, newSelectItem(expression))
+	}
+	return items, fromIndex, nil
+}
+
+//extractAggregateQuery returns aggregate query with SQL rewritten to select all fields without GROUP BY and HAVING clauses, or nil if SQL is not an aggregate query
+func extractAggregateQuery(SQL string) (string, *aggregateQuery, error) {
+	var groupByIndex, groupByEnd = findKeyword(SQL, "GROUP BY")
+	var havingIndex = indexKeyword(SQL, "HAVING")
+	var distinct = false
+	if matched := distinctExpr.FindStringIndex(SQL); matched != nil {
+		SQL = SQL[:matched[0]] + "SELECT " + SQL[matched[1]:]
+		distinct = true
+	}
+	items, fromIndex, err := parseSelectItems(SQL)
+	if err != nil {
+		return "", nil, err
+	}
+	if groupByIndex == -1 && havingIndex == -1 && !distinct && !isAggregate(items) {
+		return SQL, nil, nil
+	}
+	var result = &aggregateQuery{Items: items, Distinct: distinct}
+	var tailIndex = len(SQL)
+	if havingIndex != -1 {
+		result.Having = strings.TrimSpace(SQL[havingIndex+len("HAVING"):])
+		tailIndex = havingIndex
+	}
+	if groupByIndex != -1 {
+		if groupByIndex > tailIndex {
+			return "", nil, fmt.Errorf("GROUP BY has to precede HAVING: %v", SQL)
+		}
+		for _, column := range splitTopLevel(SQL[groupByEnd:tailIndex], ',') {
+			if column = strings.TrimSpace(column); column == "" {
+				return "", nil, fmt.Errorf("invalid GROUP BY: %v", SQL)
+			}
+			result.GroupBy = append(result.GroupBy, column)
+		}
+		tailIndex = groupByIndex
+	}
+	if distinct {
+		if isAggregate(items) || groupByIndex != -1 {
+			return "", nil, fmt.Errorf("SELECT DISTINCT with aggregates or GROUP BY is not supported: %v", SQL)
+		}
+		for _, item := range items {
+			if item.Expression == "*" {
+				return "", nil, fmt.Errorf("SELECT DISTINCT * is not supported: %v", SQL)
+			}
+			result.GroupBy = append(result.GroupBy, item.Expression)
+		}
+	}
+	var groupBy = make(map[string]bool)
+	for _, column := range result.GroupBy {
+		groupBy[column] = true
+	}
+	for _, item := range items {
+		if item.Function == "" && !groupBy[item.Expression] {
+			return "", nil, fmt.Errorf("column %v must appear in the GROUP BY clause or be used in an aggregate function", item.Expression)
+		}
+	}
+	return "SELECT * " + strings.TrimSpace(SQL[fromIndex:tailIndex]), result, nil
+}
+
+//item returns select item matching aggregate expression or group by column
+func (q *aggregateQuery) item(expression string) *selectItem {
+	var normalized = normalizeExpression(expression)
+	for _, item := range q.allItems() {
+		if normalizeExpression(item.Expression) == normalized || item.Alias == expression {
+			return item
+		}
+	}
+	return nil
+}
+
+func normalizeExpression(expression string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(strings.Replace(expression, "(", " ( ", -1)), ""))
+}
+
+//HavingSQL returns HAVING condition with aggregates replaced by accumulator names, aggregates not present in the select list are added as hidden accumulators
+func (q *aggregateQuery) HavingSQL() string {
+	return aggregateExpr.ReplaceAllStringFunc(q.Having, func(expression string) string {
+		if item := q.item(expression); item != nil {
+			return item.Name()
+		}
+		var item = newSelectItem(expression)
+		item.Alias = fmt.Sprintf("having_%d", len(q.hidden)+1)
+		q.hidden = append(q.hidden, item)
+		return item.Alias
+	})
+}
+
+//field returns output field name for select item or group by column
+func (q *aggregateQuery) field(name string) string {
+	if item := q.item(name); item != nil {
+		return item.Name()
+	}
+	return name
+}
+
+//groupKey returns $group stage _id field path for group by column
+func groupKey(column string) string {
+	return strings.Replace(column, ".", "_", -1)
+}
+
+//Pipeline returns aggregation pipeline, having criteria is expressed with accumulator names and group by columns, it is applied after accumulated values are projected
+func (q *aggregateQuery) Pipeline(criteria, having map[string]interface{}, options *queryOptions) []interface{} {
+	var pipeline = make([]interface{}, 0)
+	if len(criteria) > 0 {
+		pipeline = append(pipeline, bson.M{"$match": criteria})
+	}
+	var group = bson.D{}
+	if len(q.GroupBy) == 0 {
+		group = append(group, bson.E{Key: mongoIDKey, Value: nil})
+	} else {
+		var key = bson.D{}
+		for _, column := range q.GroupBy {
+			key = append(key, bson.E{Key: groupKey(column), Value: "$" + column})
+		}
+		group = append(group, bson.E{Key: mongoIDKey, Value: key})
+	}
+	for _, item := range q.allItems() {
+		if item.Function == "" {
+			continue
+		}
+		group = append(group, bson.E{Key: item.Name(), Value: item.Accumulator()})
+	}
+	if len(q.GroupBy) == 0 {
+		//$group outputs no document for empty input, $facet always outputs one, so that single row with empty group values is returned
+		pipeline = append(pipeline,
+			bson.M{"$facet": bson.M{"groups": []interface{}{bson.M{"$group": group}}}},
+			bson.M{"$replaceRoot": bson.M{"newRoot": bson.M{"$ifNull": []interface{}{
+				bson.M{"$arrayElemAt": []interface{}{"$groups", 0}},
+				bson.M{"$literal": q.emptyGroup()},
+			}}}},
+		)
+	} else {
+		pipeline = append(pipeline, bson.M{"$group": group})
+	}
+	var groupColumns = make(map[string]string)
+	var projection = bson.D{{Key: mongoIDKey, Value: 0}}
+	for _, column := range q.GroupBy {
+		groupColumns[column] = q.field(column)
+		projection = append(projection, bson.E{Key: groupColumns[column], Value: "$" + mongoIDKey + "." + groupKey(column)})
+	}
+	for _, item := range q.allItems() {
+		if item.Function != "" {
+			projection = append(projection, bson.E{Key: item.Name(), Value: item.Projection()})
+		}
+	}
+	pipeline = append(pipeline, bson.M{"$project": projection})
+	if len(having) > 0 {
+		pipeline = append(pipeline, bson.M{"$match": renameCriteriaFields(having, func(name string) string {
+			if column, ok := groupColumns[name]; ok {
+				return column
+			}
+			return name
+		})})
+	}
+	if len(q.hidden) > 0 {
+		var selection = bson.D{}
+		for _, item := range q.Items {
+			selection = append(selection, bson.E{Key: item.Name(), Value: 1})
+		}
+		pipeline = append(pipeline, bson.M{"$project": selection})
+	}
+	return append(pipeline, asPipelineOptions(options, q.field)...)
+}
+
+//emptyGroup returns accumulated values for empty input: COUNT is 0, DISTINCT values set is empty, other aggregates are null
+func (q *aggregateQuery) emptyGroup() bson.D {
+	var result = bson.D{{Key: mongoIDKey, Value: nil}}
+	for _, item := range q.allItems() {
+		if item.Function == "" {
+			continue
+		}
+		var value interface{}
+		if item.Distinct {
+			value = []interface{}{}
+		} else if item.Function == "COUNT" {
+			value = 0
+		}
+		result = append(result, bson.E{Key: item.Name(), Value: value})
+	}
+	return result
+}
+
+//DistinctField returns field for server side distinct command, or empty string if query requires aggregation pipeline
+func (q *aggregateQuery) DistinctField(options *queryOptions) string {
+	if !q.Distinct || len(q.Items) != 1 || q.Having != "" {
+		return ""
+	}
+	if len(options.OrderBy) > 0 || options.Limit > 0 || options.Offset > 0 {
+		return ""
+	}
+	return q.Items[0].Expression
+}
+
+//Columns returns output column names
+func (q *aggregateQuery) Columns() []string {
+	var result = make([]string, 0)
+	for _, item := range q.Items {
+		result = append(result, item.Name())
+	}
+	return result
+}
+
+//renameCriteriaFields returns criteria with field names replaced by mapping function
+func renameCriteriaFields(criteria map[string]interface{}, mapping func(name string) string) map[string]interface{} {
+	var result = make(map[string]interface{})
+	for key, value := range criteria {
+		if !strings.HasPrefix(key, "$") {
+			key = mapping(key)
+		}
+		switch actual := value.(type) {
+		case []map[string]interface{}:
+			var items = make([]map[string]interface{}, 0)
+			for _, item := range actual {
+				items = append(items, renameCriteriaFields(item, mapping))
+			}
+			value = items
+		}
+		result[key] = value
+	}
+	return result
+}
@@ -0,0 +1,121 @@
+package mgc
+
+import (
+	"fmt"
+	"github.com/viant/dsc"
+	"github.com/viant/toolbox"
+	"regexp"
+	"strings"
+)
+
+var arrayFunctionExpr = regexp.MustCompile(`(?i)^\s*(ANY|ALL|ARRAY_LENGTH)\s*\(\s*([\w.]+)\s*\)\s*$`)
+
+//sizeOperators represents operators supported by ARRAY_LENGTH
+var sizeOperators = map[string]bool{
+	"$eq":  true,
+	"$ne":  true,
+	"$lt":  true,
+	"$lte": true,
+	"$gt":  true,
+	"$gte": true,
+	"$in":  true,
+	"$nin": true,
+}
+
+func isArrayFunction(operand interface{}) bool {
+	_, _, ok := arrayFunction(operand)
+	return ok
+}
+
+//arrayFunction returns upper case array function name and its field for ANY(field), ALL(field) or ARRAY_LENGTH(field) operand
+func arrayFunction(operand interface{}) (string, string, bool) {
+	text, ok := operand.(string)
+	if !ok {
+		return "", "", false
+	}
+	matched := arrayFunctionExpr.FindStringSubmatch(text)
+	if len(matched) != 3 {
+		return "", "", false
+	}
+	return strings.ToUpper(matched[1]), matched[2], true
+}
+
+func isElemMatchOperator(operator string) bool {
+	return operator == "ELEM_MATCH" || operator == "ELEMMATCH"
+}
+
+//arrayCriterion returns criterion applying element expression to array field: ANY uses $elemMatch, ALL negated $elemMatch of negated expression, ARRAY_LENGTH $size
+func arrayCriterion(function, field string, expression interface{}) (map[string]interface{}, error) {
+	switch function {
+	case "ANY":
+		return map[string]interface{}{
+			field: map[string]interface{}{"$elemMatch": expression},
+		}, nil
+	case "ALL":
+		negated, ok := negateCriterion(field, expression)[field]
+		if !ok {
+			return nil, fmt.Errorf("unsupported ALL(%v) expression: %v", field, expression)
+		}
+		return map[string]interface{}{
+			field: map[string]interface{}{
+				"$exists": true,
+				"$not":    map[string]interface{}{"$elemMatch": negated},
+			},
+		}, nil
+	case "ARRAY_LENGTH":
+		return sizeCriterion(field, expression)
+	}
+	return nil, fmt.Errorf("unsupported array function: %v", function)
+}
+
+//sizeCriterion returns $size criterion for equality, otherwise $expr comparing array size
+func sizeCriterion(field string, expression interface{}) (map[string]interface{}, error) {
+	operators, ok := expression.(map[string]interface{})
+	if !ok || len(operators) != 1 {
+		return nil, fmt.Errorf("unsupported ARRAY_LENGTH(%v) expression: %v", field, expression)
+	}
+	for operator, value := range operators {
+		if !sizeOperators[operator] {
+			return nil, fmt.Errorf("unsupported ARRAY_LENGTH(%v) operator: %v", field, operator)
+		}
+		if operator == "$eq" {
+			return map[string]interface{}{
+				field: map[string]interface{}{"$size": value},
+			}, nil
+		}
+		var size = map[string]interface{}{
+			"$size": map[string]interface{}{"$ifNull": []interface{}{"$" + field, []interface{}{}}},
+		}
+		if operator == "$nin" {
+			return map[string]interface{}{
+				"$expr": map[string]interface{}{"$not": []interface{}{map[string]interface{}{"$in": []interface{}{size, value}}}},
+			}, nil
+		}
+		return map[string]interface{}{
+			"$expr": map[string]interface{}{operator: []interface{}{size, value}},
+		}, nil
+	}
+	return nil, nil
+}
+
+//elemMatchCriterion returns $elemMatch criterion for sub criteria group, or for '(condition)' operand
+func (c *criteriaContext) elemMatchCriterion(field string, subCriteria *dsc.SQLCriteria, value interface{}) (map[string]interface{}, error) {
+	if subCriteria == nil {
+		var condition = strings.TrimSpace(toolbox.AsString(value))
+		if strings.HasPrefix(condition, "(") && strings.HasSuffix(condition, ")") {
+			condition = condition[1 : len(condition)-1]
+		}
+		statement, err := parseQuery("SELECT * FROM elements WHERE " + condition)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse ELEM_MATCH condition %v, %v", condition, err)
+		}
+		subCriteria = statement.SQLCriteria
+	}
+	expression, err := c.asMongoCriteria(subCriteria)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		field: map[string]interface{}{"$elemMatch": expression},
+	}, nil
+}
@@ -0,0 +1,183 @@
+package mgc
+
+import (
+	"context"
+	"fmt"
+	"github.com/viant/dsc"
+	"github.com/viant/toolbox"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const (
+	batchSizeKey     = "batchSize"
+	defaultBatchSize = 1000
+)
+
+//BatchError represents batch persistence per document errors, keyed by document position in persisted data
+type BatchError struct {
+	Table  string
+	Errors map[int]error
+}
+
+//Positions returns sorted positions of failed documents
+func (e *BatchError) Positions() []int {
+	var result = make([]int, 0)
+	for position := range e.Errors {
+		result = append(result, position)
+	}
+	sort.Ints(result)
+	return result
+}
+
+//Duplicates returns sorted positions of documents rejected due to duplicate key
+func (e *BatchError) Duplicates() []int {
+	var result = make([]int, 0)
+	for _, position := range e.Positions() {
+		if mongo.IsDuplicateKeyError(e.Errors[position]) {
+			result = append(result, position)
+		}
+	}
+	return result
+}
+
+func (e *BatchError) Error() string {
+	var messages = make([]string, 0)
+	for _, position := range e.Positions() {
+		messages = append(messages, fmt.Sprintf("[%v]: %v", position, e.Errors[position]))
+	}
+	return fmt.Sprintf("failed to persist %v document(s) into %v, %v", len(e.Errors), e.Table, strings.Join(messages, ", "))
+}
+
+//batchDocument represents document to persist with its position in persisted data
+type batchDocument struct {
+	Position int
+	Document map[string]interface{}
+}
+
+//batchSize returns configured batch size, batch persistence is disabled with size lower than 2
+func (c *config) batchSize() int {
+	return c.GetInt(batchSizeKey, defaultBatchSize)
+}
+
+//BatchResult represents persisted batch inserted and updated documents count
+type BatchResult struct {
+	Inserted int
+	Updated  int
+}
+
+//PersistBatches persists data with manager bulk writes, it returns inserted and updated counts for each persisted batch,
+//documents failed in any batch are reported with *BatchError
+func PersistBatches(dscManager dsc.Manager, dataPointer interface{}, table string, provider dsc.DmlProvider) ([]*BatchResult, error) {
+	mgcManager, ok := dscManager.(*manager)
+	if !ok {
+		return nil, fmt.Errorf("unsupported manager: %T", dscManager)
+	}
+	connection, err := mgcManager.ConnectionProvider().Get()
+	if err != nil {
+		return nil, err
+	}
+	defer connection.Close()
+	return mgcManager.persistBatches(connection, dataPointer, table, provider)
+}
+
+//PersistAllOnConnection persists data with unordered bulk writes, documents with key are upserted, others are inserted,
+//within transaction data is persisted with statements executed on the transaction
+func (m *manager) PersistAllOnConnection(connection dsc.Connection, dataPointer interface{}, table string, provider dsc.DmlProvider) (inserted int, updated int, err error) {
+	results, err := m.persistBatches(connection, dataPointer, table, provider)
+	for _, result := range results {
+		inserted += result.Inserted
+		updated += result.Updated
+	}
+	return inserted, updated, err
+}
+
+//persistBatches persists data in batches of configured size, it returns inserted and updated counts for each batch,
+//with batch size lower than 2 or within transaction data is persisted record by record as single batch
+func (m *manager) persistBatches(connection dsc.Connection, dataPointer interface{}, table string, provider dsc.DmlProvider) ([]*BatchResult, error) {
+	var batchSize = m.config.batchSize()
+	if batchSize < 2 || asTransaction(connection) != nil {
+		inserted, updated, err := m.AbstractManager.PersistAllOnConnection(connection, dataPointer, table, provider)
+		return []*BatchResult{{Inserted: inserted, Updated: updated}}, err
+	}
+	provider, err := dsc.NewDmlProviderIfNeeded(provider, table, reflect.TypeOf(dataPointer))
+	if err != nil {
+		return nil, err
+	}
+	db, err := asDatabase(connection)
+	if err != nil {
+		return nil, err
+	}
+	var documents = make([]*batchDocument, 0)
+	toolbox.ProcessSlice(dataPointer, func(item interface{}) bool {
+		parametrizedSQL := provider.Get(dsc.SQLTypeInsert, item)
+		var command *Command
+		if command, err = m.config.translateDML(parametrizedSQL.SQL, parametrizedSQL.Values); err != nil {
+			return false
+		}
+		for _, document := range command.Documents {
+			if aMap, ok := asDocument(document); ok {
+				documents = append(documents, &batchDocument{Position: len(documents), Document: aMap})
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	var results = make([]*BatchResult, 0)
+	var batchError = &BatchError{Table: table, Errors: make(map[int]error)}
+	collection := db.Collection(table)
+	for start := 0; start < len(documents); start += batchSize {
+		var end = start + batchSize
+		if end > len(documents) {
+			end = len(documents)
+		}
+		result, err := m.persistBatch(context.Background(), collection, documents[start:end], batchError)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, result)
+	}
+	if len(batchError.Errors) > 0 {
+		return results, batchError
+	}
+	return results, nil
+}
+
+//persistBatch runs unordered bulk write, per document errors are collected into batch error, it returns batch inserted and updated counts
+func (m *manager) persistBatch(ctx context.Context, collection *mongo.Collection, documents []*batchDocument, batchError *BatchError) (*BatchResult, error) {
+	var models = make([]mongo.WriteModel, 0, len(documents))
+	for _, document := range documents {
+		id, hasKey := document.Document[mongoIDKey]
+		if !hasKey {
+			models = append(models, mongo.NewInsertOneModel().SetDocument(document.Document))
+			continue
+		}
+		var fields = bson.M{}
+		for k, v := range document.Document {
+			if k != mongoIDKey {
+				fields[k] = v
+			}
+		}
+		models = append(models, mongo.NewUpdateOneModel().SetFilter(bson.M{mongoIDKey: id}).SetUpdate(bson.M{"$set": fields}).SetUpsert(true))
+	}
+	result, err := collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		bulkError, ok := err.(mongo.BulkWriteException)
+		if !ok || bulkError.WriteConcernError != nil || result == nil {
+			return nil, err
+		}
+		for _, writeError := range bulkError.WriteErrors {
+			if writeError.Index < 0 || writeError.Index >= len(documents) {
+				return nil, err
+			}
+			batchError.Errors[documents[writeError.Index].Position] = writeError.WriteError
+		}
+	}
+	return &BatchResult{Inserted: int(result.InsertedCount + result.UpsertedCount), Updated: int(result.MatchedCount)}, nil
+}
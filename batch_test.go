@@ -0,0 +1,23 @@
+package mgc_test
+
+import (
+	"errors"
+	"github.com/adrianwit/mgc"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/mongo"
+	"testing"
+)
+
+func TestBatchError(t *testing.T) {
+	var batchError = &mgc.BatchError{
+		Table: "users",
+		Errors: map[int]error{
+			7: mongo.WriteError{Code: 11000, Message: "E11000 duplicate key error"},
+			1: errors.New("document too large"),
+			3: mongo.CommandError{Code: 11000, Message: "E11000 duplicate key error"},
+		},
+	}
+	assert.EqualValues(t, []int{1, 3, 7}, batchError.Positions())
+	assert.EqualValues(t, []int{3, 7}, batchError.Duplicates())
+	assert.EqualValues(t, "failed to persist 3 document(s) into users, [1]: document too large, [3]: E11000 duplicate key error, [7]: E11000 duplicate key error", batchError.Error())
+}
@@ -1,52 +1,63 @@
 package mgc
 
 import (
+	"context"
 	"fmt"
-	mgo "github.com/globalsign/mgo"
 	"github.com/pkg/errors"
 	"github.com/viant/dsc"
 	"github.com/viant/toolbox/url"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	neturl "net/url"
+	"strings"
 	"time"
 )
 
 const (
-	hostKey    = "host"
-	portKey    = "port"
-	dbnameKey  = "dbname"
-	timeoutKey = "timeoutSec"
+	uriKey         = "uri"
+	hostKey        = "host"
+	portKey        = "port"
+	dbnameKey      = "dbname"
+	timeoutKey     = "timeoutSec"
+	defaultTimeout = 10 * time.Second
 )
 
-var SessionPointer = (*mgo.Session)(nil)
-var DbPointer = (*mgo.Database)(nil)
+//ClientPointer represents connection Unwrap target for *mongo.Client
+var ClientPointer = (*mongo.Client)(nil)
 
-func asDatabase(connection dsc.Connection) (*mgo.Database, error) {
-	db := connection.Unwrap(DbPointer).(*mgo.Database)
+//SessionPointer represents connection Unwrap target for *mongo.Client, which replaced mgo session
+var SessionPointer = ClientPointer
+
+//DbPointer represents connection Unwrap target for *mongo.Database
+var DbPointer = (*mongo.Database)(nil)
+
+func asDatabase(connection dsc.Connection) (*mongo.Database, error) {
+	db := connection.Unwrap(DbPointer).(*mongo.Database)
 	return db, nil
 }
 
-func asSession(connection dsc.Connection) (*mgo.Session, error) {
-	session := connection.Unwrap(SessionPointer).(*mgo.Session)
-	return session, nil
+func asClient(connection dsc.Connection) (*mongo.Client, error) {
+	client := connection.Unwrap(ClientPointer).(*mongo.Client)
+	return client, nil
 }
 
 type connection struct {
 	*dsc.AbstractConnection
-	session *mgo.Session
-	dbName  string
+	client      *mongo.Client
+	dbName      string
+	transaction *transaction
 }
 
 func (c *connection) CloseNow() error {
-	session := c.session
-	session.Close()
-	return nil
+	return c.client.Disconnect(context.Background())
 }
 
 func (c *connection) Unwrap(targetType interface{}) interface{} {
-	if targetType == SessionPointer {
-		return c.session
+	if targetType == ClientPointer {
+		return c.client
 	}
 	if targetType == DbPointer {
-		return c.session.DB(c.dbName)
+		return c.client.Database(c.dbName)
 	}
 	panic(fmt.Sprintf("unsupported targetType type %v", targetType))
 }
@@ -55,42 +66,96 @@ type connectionProvider struct {
 	*dsc.AbstractConnectionProvider
 }
 
-func (p *connectionProvider) NewConnection() (dsc.Connection, error) {
-	config := p.ConnectionProvider.Config()
+//credential represents credentials config, following mgo credential fields
+type credential struct {
+	Username  string
+	Password  string
+	Source    string
+	Mechanism string
+}
 
-	dbname := config.Get(dbnameKey)
-	if dbname == "" {
-		return nil, errors.New("dbname was empty")
+//databaseName returns database name from uri config parameter path, or dbname if uri has no database
+func databaseName(config *dsc.Config) (string, error) {
+	var result = ""
+	if URI := config.Get(uriKey); URI != "" {
+		parsedURI, err := neturl.Parse(URI)
+		if err != nil {
+			return "", fmt.Errorf("invalid %v, %v", uriKey, err)
+		}
+		result = strings.TrimPrefix(parsedURI.Path, "/")
 	}
-	host := config.Get(hostKey)
-	if host == "" {
-		return nil, errors.New("host was empty")
+	if result == "" {
+		result = config.Get(dbnameKey)
 	}
-	port := config.GetInt(portKey, 27017)
-	var err error
-	hostname := fmt.Sprintf("%v:%d", host, port)
-	var session *mgo.Session
-	if config.Has(timeoutKey) {
-		var timeout = config.GetDuration(timeoutKey, time.Second, 5*time.Second)
-		session, err = mgo.DialWithTimeout(hostname, timeout)
+	if result == "" {
+		return "", errors.New("dbname was empty")
+	}
+	return result, nil
+}
+
+//newClientOptions returns client options for uri config parameter, or for host and port if uri is not set,
+//URI options i.e. replicaSet, authSource or write concern are applied by the driver
+func newClientOptions(config *dsc.Config) (*options.ClientOptions, error) {
+	var result = options.Client()
+	if URI := config.Get(uriKey); URI != "" {
+		if err := result.ApplyURI(URI).Validate(); err != nil {
+			return nil, fmt.Errorf("invalid %v, %v", uriKey, err)
+		}
 	} else {
-		session, err = mgo.Dial(hostname)
+		host := config.Get(hostKey)
+		if host == "" {
+			return nil, errors.New("host was empty")
+		}
+		port := config.GetInt(portKey, 27017)
+		result.SetHosts([]string{fmt.Sprintf("%v:%d", host, port)})
 	}
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to %v, %v", hostname, err)
+	if config.Has(timeoutKey) {
+		timeout := config.GetDuration(timeoutKey, time.Second, 5*time.Second)
+		result.SetConnectTimeout(timeout).SetServerSelectionTimeout(timeout)
+	} else {
+		if result.ConnectTimeout == nil {
+			result.SetConnectTimeout(defaultTimeout)
+		}
+		if result.ServerSelectionTimeout == nil {
+			result.SetServerSelectionTimeout(defaultTimeout)
+		}
 	}
-	if p.Config().Credentials != "" {
-
-		var credential = &mgo.Credential{}
-		resource := url.NewResource(p.Config().Credentials)
+	if config.Credentials != "" {
+		var credential = &credential{}
+		resource := url.NewResource(config.Credentials)
 		if err := resource.Decode(credential); err != nil {
 			return nil, err
 		}
-		if err = session.Login(credential); err != nil {
-			return nil, err
+		result.SetAuth(options.Credential{
+			Username:      credential.Username,
+			Password:      credential.Password,
+			AuthSource:    credential.Source,
+			AuthMechanism: credential.Mechanism,
+		})
+	}
+	return result, nil
+}
+
+func (p *connectionProvider) NewConnection() (dsc.Connection, error) {
+	config := p.ConnectionProvider.Config()
+	dbName, err := databaseName(config)
+	if err != nil {
+		return nil, err
+	}
+	clientOptions, err := newClientOptions(config)
+	if err != nil {
+		return nil, err
+	}
+	client, err := mongo.Connect(context.Background(), clientOptions)
+	if err == nil {
+		if err = client.Ping(context.Background(), nil); err != nil {
+			_ = client.Disconnect(context.Background())
 		}
 	}
-	var mgoConnection = &connection{session: session, dbName: dbname}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %v, %v", strings.Join(clientOptions.Hosts, ","), err)
+	}
+	var mgoConnection = &connection{client: client, dbName: dbName}
 	var super = dsc.NewAbstractConnection(config, p.ConnectionProvider.ConnectionPool(), mgoConnection)
 	mgoConnection.AbstractConnection = super
 	return mgoConnection, nil
@@ -23,3 +23,35 @@ func TestNewConnection(t *testing.T) {
 	_, err = provider.NewConnection()
 	assert.NotNil(t, err)
 }
+
+func TestNewConnection_URI(t *testing.T) {
+	var params = map[string]interface{}{
+		"uri":        "mongodb://127.3.0.1:1111,127.3.0.2:1111/mydb?replicaSet=rs0&authSource=admin&w=majority&wtimeoutMS=100",
+		"timeoutSec": "1",
+	}
+	config, err := dsc.NewConfigWithParameters("mgc", "", "", params)
+	if !assert.Nil(t, err) {
+		return
+	}
+	factory := dsc.NewManagerFactory()
+	manager, err := factory.Create(config)
+	if !assert.Nil(t, err) {
+		return
+	}
+	provider := manager.ConnectionProvider()
+	_, err = provider.NewConnection()
+	assert.NotNil(t, err)
+}
+
+func TestManagerFactory_CreateFromURL(t *testing.T) {
+	factory, err := dsc.GetManagerFactory("mgc")
+	if !assert.Nil(t, err) {
+		return
+	}
+	manager, err := factory.CreateFromURL("mongodb://127.3.0.1:1111/mydb?w=1")
+	if assert.Nil(t, err) {
+		assert.EqualValues(t, "mongodb://127.3.0.1:1111/mydb?w=1", manager.Config().Get("uri"))
+	}
+	_, err = factory.CreateFromURL("mongodb://127.3.0.1:1111")
+	assert.NotNil(t, err)
+}
@@ -1,13 +1,18 @@
 package mgc
 
 import (
-	"cloud.google.com/go/container"
+	"bytes"
 	"fmt"
 	"github.com/viant/dsc"
 	"github.com/viant/toolbox"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"regexp"
 	"strings"
 )
 
+//bsonNullType represents BSON null type number used by $type operator
+const bsonNullType = 10
+
 //CriterionProvider represents criteria provider
 type CriterionProvider func(value interface{}) (interface{}, error)
 
@@ -46,27 +51,118 @@ var OperatorMapping = map[string]CriterionProvider{
 	},
 
 	"IN": func(value interface{}) (interface{}, error) {
-		if toolbox.IsString(value) {
-			value = strings.Split(toolbox.AsString(value), ",")
-		}
-		if toolbox.IsSlice(value) {
-			fmt.Errorf("expected slice but had: %T", value)
-		}
 		return map[string]interface{}{
-			"$in": toolbox.AsSlice(value),
+			"$in": asInValues(value),
 		}, nil
 	},
 	"NOT IN": func(value interface{}) (interface{}, error) {
-		if toolbox.IsString(value) {
-			value = strings.Split(toolbox.AsString(value), ",")
+		return map[string]interface{}{
+			"$nin": asInValues(value),
+		}, nil
+	},
+	"LIKE": func(value interface{}) (interface{}, error) {
+		return likeCriterion(value, false)
+	},
+	"NOT LIKE": func(value interface{}) (interface{}, error) {
+		return likeCriterion(value, true)
+	},
+	"ILIKE": func(value interface{}) (interface{}, error) {
+		return likeCriterion(value, false)
+	},
+	"NOT ILIKE": func(value interface{}) (interface{}, error) {
+		return likeCriterion(value, true)
+	},
+	"CONTAINS": func(value interface{}) (interface{}, error) {
+		return map[string]interface{}{
+			"$all": asInValues(value),
+		}, nil
+	},
+	"CONTAINS ALL": func(value interface{}) (interface{}, error) {
+		return map[string]interface{}{
+			"$all": asInValues(value),
+		}, nil
+	},
+	"CONTAINS ANY": func(value interface{}) (interface{}, error) {
+		return map[string]interface{}{
+			"$in": asInValues(value),
+		}, nil
+	},
+}
+
+//inverseOperators represents operators with direct negated counterpart, other negated operators use $not
+var inverseOperators = map[string]string{
+	"=":         "!=",
+	"!=":        "=",
+	"IN":        "NOT IN",
+	"NOT IN":    "IN",
+	"LIKE":      "NOT LIKE",
+	"NOT LIKE":  "LIKE",
+	"ILIKE":     "NOT ILIKE",
+	"NOT ILIKE": "ILIKE",
+}
+
+func asInValues(value interface{}) []interface{} {
+	if toolbox.IsString(value) {
+		value = strings.Split(toolbox.AsString(value), ",")
+	}
+	if !isArray(value) {
+		return []interface{}{value}
+	}
+	if items, ok := asArray(value); ok {
+		return items
+	}
+	return toolbox.AsSlice(value)
+}
+
+//LikeToRegExpr converts SQL LIKE pattern into anchored regular expression, % matches any sequence, _ any single character, \ escapes wildcard
+func LikeToRegExpr(pattern string) string {
+	var result = bytes.NewBufferString("^")
+	var literal = bytes.NewBufferString("")
+	flushLiteral := func() {
+		if literal.Len() > 0 {
+			result.WriteString(regexp.QuoteMeta(literal.String()))
+			literal.Reset()
 		}
-		if toolbox.IsSlice(value) {
-			fmt.Errorf("expected slice but had: %T", value)
+	}
+	var runes = []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		switch runes[i] {
+		case '\\':
+			if i+1 < len(runes) {
+				i++
+			}
+			literal.WriteRune(runes[i])
+		case '%':
+			flushLiteral()
+			result.WriteString(".*")
+		case '_':
+			flushLiteral()
+			result.WriteString(".")
+		default:
+			literal.WriteRune(runes[i])
 		}
+	}
+	flushLiteral()
+	result.WriteString("$")
+	return result.String()
+}
+
+//likeCriterion returns case-insensitive anchored $regex criterion for LIKE pattern, ILIKE is an alias of LIKE
+func likeCriterion(value interface{}, negate bool) (interface{}, error) {
+	if value == nil || isArray(value) {
+		return nil, fmt.Errorf("expected LIKE pattern but had: %T", value)
+	}
+	var options = "is"
+	var expr = LikeToRegExpr(toolbox.AsString(value))
+	if negate {
 		return map[string]interface{}{
-			"$nin": value,
+			"$not": primitive.Regex{Pattern: expr, Options: options},
 		}, nil
-	},
+	}
+	return map[string]interface{}{
+		"$regex":   expr,
+		"$options": options,
+	}, nil
 }
 
 func hasPlaceholders(candidate interface{}) bool {
@@ -78,11 +174,21 @@ func hasPlaceholders(candidate interface{}) bool {
 
 }
 
-func MapCriterion(operator string, value interface{}, parameters []interface{}, paramIndex *int) (interface{}, error) {
+//MapValue resolves SQL operand value, placeholders are replaced with parameters starting from paramIndex, literals are converted to their corresponding types
+func MapValue(value interface{}, parameters []interface{}, paramIndex *int) (interface{}, error) {
 	var err error
 	if textValue, ok := value.(string); ok {
+		if typeName, operand, ok := typedLiteral(textValue); ok {
+			if value, err = MapValue(operand, parameters, paramIndex); err != nil {
+				return nil, err
+			}
+			return asTypedValue(typeName, value)
+		}
 		placeholderCount := strings.Count(textValue, "?")
 		if placeholderCount == 1 {
+			if *paramIndex >= len(parameters) {
+				return nil, fmt.Errorf("array out of bound, %v %v", *paramIndex, len(parameters))
+			}
 			value = parameters[*paramIndex]
 			*paramIndex++
 			if textParam, ok := value.(string); ok {
@@ -99,21 +205,23 @@ func MapCriterion(operator string, value interface{}, parameters []interface{},
 			}
 			value = aSlice
 		} else {
+			textValue = strings.TrimSpace(textValue)
 			if strings.HasPrefix(textValue, "'") {
 				value = strings.Trim(textValue, "'")
-			} else if strings.ToLower(textValue) == "true" || strings.ToLower(textValue) == "false" {
-				value = toolbox.AsBoolean(textValue)
-			} else if strings.Contains(textValue, ".") {
-				if value, err = toolbox.ToFloat(textValue); err != nil {
-					return nil, err
-				}
-			} else {
-				if value, err = toolbox.ToInt(textValue); err != nil {
-					return nil, err
-				}
+			} else if value, err = asLiteralValue(textValue); err != nil {
+				return nil, err
 			}
 		}
 	}
+	return value, nil
+}
+
+//MapCriterion maps SQL operator and its value into mongo criterion
+func MapCriterion(operator string, value interface{}, parameters []interface{}, paramIndex *int) (interface{}, error) {
+	value, err := MapValue(value, parameters, paramIndex)
+	if err != nil {
+		return nil, err
+	}
 	criterionProvidder, has := OperatorMapping[operator]
 	if !has {
 		return nil, fmt.Errorf("unsupported operator: %v", operator)
@@ -121,57 +229,264 @@ func MapCriterion(operator string, value interface{}, parameters []interface{},
 	return criterionProvidder(value)
 }
 
-func asMongoCriterion(criterion *dsc.SQLCriterion, parameters []interface{}, parameterIndex *int) (result map[string]interface{}, err error) {
-	result = make(map[string]interface{})
+//criteriaContext represents criteria translation state
+type criteriaContext struct {
+	parameters     []interface{}
+	parameterIndex int
+	missingAsNull  bool //when set IS NULL matches also documents without the field
+}
+
+func newCriteriaContext(parameters []interface{}, missingAsNull bool) *criteriaContext {
+	return &criteriaContext{
+		parameters:    parameters,
+		missingAsNull: missingAsNull,
+	}
+}
+
+func splitBetweenOperand(value interface{}) ([]interface{}, error) {
+	if isArray(value) {
+		var bounds = asInValues(value)
+		if len(bounds) != 2 {
+			return nil, fmt.Errorf("expected BETWEEN with 2 values but had: %v", len(bounds))
+		}
+		return bounds, nil
+	}
+	var text = toolbox.AsString(value)
+	var index = strings.Index(strings.ToUpper(text), " AND ")
+	if index == -1 {
+		return nil, fmt.Errorf("invalid BETWEEN operand: %v", text)
+	}
+	return []interface{}{strings.TrimSpace(text[:index]), strings.TrimSpace(text[index+5:])}, nil
+}
+
+func (c *criteriaContext) betweenCriterion(value interface{}) (interface{}, error) {
+	bounds, err := splitBetweenOperand(value)
+	if err != nil {
+		return nil, err
+	}
+	var from, to interface{}
+	if from, err = MapValue(bounds[0], c.parameters, &c.parameterIndex); err != nil {
+		return nil, err
+	}
+	if to, err = MapValue(bounds[1], c.parameters, &c.parameterIndex); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"$gte": from,
+		"$lte": to,
+	}, nil
+}
+
+func (c *criteriaContext) nullCriterion(isNull bool) interface{} {
+	if c.missingAsNull {
+		if isNull {
+			return map[string]interface{}{"$eq": nil}
+		}
+		return map[string]interface{}{"$ne": nil}
+	}
+	if isNull {
+		return map[string]interface{}{"$exists": true, "$eq": nil}
+	}
+	return map[string]interface{}{"$not": map[string]interface{}{"$type": bsonNullType}}
+}
+
+//isNullOperand returns true if operand is NULL or NOT NULL keyword, with flag indicating NOT NULL
+func isNullOperand(value interface{}) (isNullKeyword bool, isNotNull bool) {
+	text, ok := value.(string)
+	if !ok {
+		return false, false
+	}
+	switch strings.ToUpper(strings.Join(strings.Fields(text), " ")) {
+	case "NULL":
+		return true, false
+	case "NOT NULL":
+		return true, true
+	}
+	return false, false
+}
+
+//mirroredOperators represents operators used when operands are swapped, i.e. '? < x' becomes 'x > ?'
+var mirroredOperators = map[string]string{
+	"<":  ">",
+	"<=": ">=",
+	">":  "<",
+	">=": "<=",
+}
+
+func normalizeOperator(operator string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(operator), " "))
+}
+
+func (c *criteriaContext) asMongoCriterion(criterion *dsc.SQLCriterion) (map[string]interface{}, error) {
+	var operator = normalizeOperator(criterion.Operator)
+	if criterion.SQLCriteria != nil && !isElemMatchOperator(operator) {
+		return c.asMongoGroup(criterion)
+	}
 	var key = toolbox.AsString(criterion.LeftOperand)
 	var value = criterion.RightOperand
-	if hasPlaceholders(criterion.LeftOperand) {
+	if hasPlaceholders(criterion.LeftOperand) || isArrayFunction(criterion.RightOperand) {
 		key = toolbox.AsString(criterion.RightOperand)
 		value = criterion.LeftOperand
+		if mirrored, ok := mirroredOperators[operator]; ok {
+			operator = mirrored
+		}
+	}
+	var inverse = criterion.Inverse
+	if _, has := OperatorMapping[operator]; !has && strings.HasPrefix(operator, "NOT ") {
+		operator = strings.TrimPrefix(operator, "NOT ")
+		inverse = !inverse
 	}
+	if isElemMatchOperator(operator) {
+		result, err := c.elemMatchCriterion(key, criterion.SQLCriteria, value)
+		if err != nil || !inverse {
+			return result, err
+		}
+		return map[string]interface{}{"$nor": []map[string]interface{}{result}}, nil
+	}
+	if function, field, ok := arrayFunction(key); ok {
+		expression, err := c.fieldCriterion(field, operator, value, false)
+		if err != nil {
+			return nil, err
+		}
+		result, err := arrayCriterion(function, field, expression[field])
+		if err != nil || !inverse {
+			return result, err
+		}
+		return map[string]interface{}{"$nor": []map[string]interface{}{result}}, nil
+	}
+	return c.fieldCriterion(key, operator, value, inverse)
+}
 
-	var operator = strings.ToUpper(criterion.Operator)
-	if criterion.Inverse {
-		if operator == "IN" {
-			operator = "NOT " + operator
-		} else if operator == "=" {
-			operator = "!" + operator
-		} else {
-			return nil, fmt.Errorf("unsupported operator: NOT %v", container.Op{})
+//fieldCriterion returns criterion for field, operator and its operand
+func (c *criteriaContext) fieldCriterion(key, operator string, value interface{}, inverse bool) (result map[string]interface{}, err error) {
+	result = make(map[string]interface{})
+	switch operator {
+	case "IS", "IS NOT":
+		isNullKeyword, isNotNull := isNullOperand(value)
+		if !isNullKeyword {
+			return nil, fmt.Errorf("unsupported operand: %v %v", operator, value)
+		}
+		var isNull = (operator == "IS") != isNotNull
+		if inverse {
+			isNull = !isNull
+		}
+		result[key] = c.nullCriterion(isNull)
+		return result, nil
+	case "BETWEEN":
+		if result[key], err = c.betweenCriterion(value); err != nil {
+			return nil, fmt.Errorf("failed to map %v: %v", operator, err)
+		}
+	default:
+		var negate = false
+		if inverse {
+			if inverseOperator, ok := inverseOperators[operator]; ok {
+				operator = inverseOperator
+				inverse = false
+			} else {
+				negate = true
+			}
+		}
+		if result[key], err = MapCriterion(operator, value, c.parameters, &c.parameterIndex); err != nil {
+			if negate {
+				return nil, fmt.Errorf("failed to map NOT %v: %v", operator, err)
+			}
+			return nil, err
 		}
 	}
-	result[key], err = MapCriterion(operator, value, parameters, parameterIndex)
-	if err != nil {
-		return nil, err
+	if inverse {
+		return negateCriterion(key, result[key]), nil
 	}
 	return result, err
 }
 
-func AsMongoCriteria(sqlCriteria *dsc.SQLCriteria, parameters []interface{}) (map[string]interface{}, error) {
+//negateCriterion negates field criterion with $not, or with $nor if criterion is not an operator expression
+func negateCriterion(key string, criterion interface{}) map[string]interface{} {
+	if expression, ok := asDocument(criterion); ok && isOperatorExpression(expression) {
+		if pattern, ok := expression["$regex"]; ok && len(expression) <= 2 {
+			return map[string]interface{}{
+				key: map[string]interface{}{
+					"$not": primitive.Regex{Pattern: toolbox.AsString(pattern), Options: toolbox.AsString(expression["$options"])},
+				},
+			}
+		}
+		return map[string]interface{}{
+			key: map[string]interface{}{
+				"$not": expression,
+			},
+		}
+	}
+	return map[string]interface{}{
+		"$nor": []map[string]interface{}{
+			{key: criterion},
+		},
+	}
+}
+
+func isOperatorExpression(expression map[string]interface{}) bool {
+	if len(expression) == 0 {
+		return false
+	}
+	for k := range expression {
+		if !strings.HasPrefix(k, "$") {
+			return false
+		}
+	}
+	return true
+}
+
+//asMongoCriteria converts criteria group into mongo criteria, nested groups are converted recursively, with nested groups sharing the same logical operator merged into the parent
+func (c *criteriaContext) asMongoCriteria(sqlCriteria *dsc.SQLCriteria) (map[string]interface{}, error) {
 	var result = make(map[string]interface{})
-	if len(sqlCriteria.Criteria) == 0 {
+	if sqlCriteria == nil || len(sqlCriteria.Criteria) == 0 {
 		return result, nil
 	}
 
-	var parameterIndex = 0
-
 	if len(sqlCriteria.Criteria) == 1 {
-		return asMongoCriterion(sqlCriteria.Criteria[0], parameters, &parameterIndex)
+		return c.asMongoCriterion(sqlCriteria.Criteria[0])
 	}
 
 	var logicalOperator = "$and"
-	if strings.ToUpper(sqlCriteria.LogicalOperator) == "OR" {
+	if strings.ToUpper(strings.TrimSpace(sqlCriteria.LogicalOperator)) == "OR" {
 		logicalOperator = "$or"
 	}
 	mongoCriteria := make([]map[string]interface{}, 0)
 
 	for _, criterion := range sqlCriteria.Criteria {
-		mongoCriterion, err := asMongoCriterion(criterion, parameters, &parameterIndex)
+		mongoCriterion, err := c.asMongoCriterion(criterion)
 		if err != nil {
 			return nil, err
 		}
+		if len(mongoCriterion) == 0 {
+			continue
+		}
+		if nested, ok := mongoCriterion[logicalOperator]; ok && len(mongoCriterion) == 1 {
+			mongoCriteria = append(mongoCriteria, nested.([]map[string]interface{})...)
+			continue
+		}
 		mongoCriteria = append(mongoCriteria, mongoCriterion)
 	}
+	if len(mongoCriteria) == 1 {
+		return mongoCriteria[0], nil
+	}
 	result[logicalOperator] = mongoCriteria
 	return result, nil
 }
+
+//asMongoGroup converts parenthesised criteria group, negated group is converted into $nor
+func (c *criteriaContext) asMongoGroup(criterion *dsc.SQLCriterion) (map[string]interface{}, error) {
+	group, err := c.asMongoCriteria(criterion.SQLCriteria)
+	if err != nil {
+		return nil, err
+	}
+	if criterion.Inverse && len(group) > 0 {
+		return map[string]interface{}{
+			"$nor": []map[string]interface{}{group},
+		}, nil
+	}
+	return group, nil
+}
+
+//AsMongoCriteria converts SQL criteria into mongo criteria, IS NULL matches also documents without the field
+func AsMongoCriteria(sqlCriteria *dsc.SQLCriteria, parameters []interface{}) (map[string]interface{}, error) {
+	return newCriteriaContext(parameters, true).asMongoCriteria(sqlCriteria)
+}
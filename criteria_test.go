@@ -4,7 +4,9 @@ import (
 	"github.com/adrianwit/mgc"
 	"github.com/stretchr/testify/assert"
 	"github.com/viant/dsc"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"testing"
+	"time"
 )
 
 func TestAsMongoCriteria(t *testing.T) {
@@ -29,3 +31,502 @@ func TestAsMongoCriteria(t *testing.T) {
 		}
 	}
 }
+
+func TestLikeToRegExpr(t *testing.T) {
+	var useCases = []struct {
+		Description string
+		Pattern     string
+		Expected    string
+	}{
+		{
+			Description: "prefix pattern",
+			Pattern:     "abc%",
+			Expected:    "^abc.*$",
+		},
+		{
+			Description: "single char wildcard",
+			Pattern:     "a_c",
+			Expected:    "^a.c$",
+		},
+		{
+			Description: "regexp meta characters",
+			Pattern:     "a.b(c)%",
+			Expected:    `^a\.b\(c\).*$`,
+		},
+		{
+			Description: "escaped wildcard",
+			Pattern:     `100\%`,
+			Expected:    "^100%$",
+		},
+	}
+	for _, useCase := range useCases {
+		assert.EqualValues(t, useCase.Expected, mgc.LikeToRegExpr(useCase.Pattern), useCase.Description)
+	}
+}
+
+func TestAsMongoCriteria_Like(t *testing.T) {
+	criteria := &dsc.SQLCriteria{
+		Criteria: []*dsc.SQLCriterion{
+			{
+				LeftOperand:  "name",
+				Operator:     "LIKE",
+				RightOperand: "?",
+			},
+		},
+	}
+	criteriaValues, err := mgc.AsMongoCriteria(criteria, []interface{}{"ab%"})
+	if assert.Nil(t, err) {
+		assert.EqualValues(t, map[string]interface{}{
+			"$regex":   "^ab.*$",
+			"$options": "is",
+		}, criteriaValues["name"])
+	}
+	criteria.Criteria[0].Operator = "ILIKE"
+	criteria.Criteria[0].Inverse = true
+	criteriaValues, err = mgc.AsMongoCriteria(criteria, []interface{}{"ab%"})
+	if assert.Nil(t, err) {
+		assert.EqualValues(t, map[string]interface{}{
+			"$not": primitive.Regex{Pattern: "^ab.*$", Options: "is"},
+		}, criteriaValues["name"])
+	}
+}
+
+func TestAsMongoCriteria_BetweenAndNull(t *testing.T) {
+	var useCases = []struct {
+		Description string
+		Criteria    *dsc.SQLCriteria
+		Parameters  []interface{}
+		Expected    map[string]interface{}
+	}{
+		{
+			Description: "between with placeholders",
+			Criteria: &dsc.SQLCriteria{
+				Criteria: []*dsc.SQLCriterion{
+					{LeftOperand: "ts", Operator: "BETWEEN", RightOperand: "? AND ?"},
+				},
+			},
+			Parameters: []interface{}{1, 10},
+			Expected: map[string]interface{}{
+				"ts": map[string]interface{}{"$gte": 1, "$lte": 10},
+			},
+		},
+		{
+			Description: "between with mixed operands followed by placeholder",
+			Criteria: &dsc.SQLCriteria{
+				LogicalOperator: "AND",
+				Criteria: []*dsc.SQLCriterion{
+					{LeftOperand: "ts", Operator: "BETWEEN", RightOperand: "? AND 10"},
+					{LeftOperand: "id", Operator: "=", RightOperand: "?"},
+				},
+			},
+			Parameters: []interface{}{1, 3},
+			Expected: map[string]interface{}{
+				"$and": []map[string]interface{}{
+					{"ts": map[string]interface{}{"$gte": 1, "$lte": 10}},
+					{"id": map[string]interface{}{"$eq": 3}},
+				},
+			},
+		},
+		{
+			Description: "is null",
+			Criteria: &dsc.SQLCriteria{
+				Criteria: []*dsc.SQLCriterion{
+					{LeftOperand: "deleted_at", Operator: "IS", RightOperand: "NULL"},
+				},
+			},
+			Expected: map[string]interface{}{
+				"deleted_at": map[string]interface{}{"$eq": nil},
+			},
+		},
+		{
+			Description: "is not null",
+			Criteria: &dsc.SQLCriteria{
+				Criteria: []*dsc.SQLCriterion{
+					{LeftOperand: "deleted_at", Operator: "IS", RightOperand: "NOT NULL"},
+				},
+			},
+			Expected: map[string]interface{}{
+				"deleted_at": map[string]interface{}{"$ne": nil},
+			},
+		},
+	}
+	for _, useCase := range useCases {
+		actual, err := mgc.AsMongoCriteria(useCase.Criteria, useCase.Parameters)
+		if !assert.Nil(t, err, useCase.Description) {
+			continue
+		}
+		assert.EqualValues(t, useCase.Expected, actual, useCase.Description)
+	}
+}
+
+func TestAsMongoCriteria_Nested(t *testing.T) {
+	var useCases = []struct {
+		Description string
+		Criteria    *dsc.SQLCriteria
+		Parameters  []interface{}
+		Expected    map[string]interface{}
+	}{
+		{
+			Description: "a = 1 AND (b = 2 OR c = 3)",
+			Criteria: &dsc.SQLCriteria{
+				LogicalOperator: "AND",
+				Criteria: []*dsc.SQLCriterion{
+					{LeftOperand: "a", Operator: "=", RightOperand: "1"},
+					{SQLCriteria: &dsc.SQLCriteria{
+						LogicalOperator: "OR",
+						Criteria: []*dsc.SQLCriterion{
+							{LeftOperand: "b", Operator: "=", RightOperand: "2"},
+							{LeftOperand: "c", Operator: "=", RightOperand: "3"},
+						},
+					}},
+				},
+			},
+			Expected: map[string]interface{}{
+				"$and": []map[string]interface{}{
+					{"a": map[string]interface{}{"$eq": 1}},
+					{"$or": []map[string]interface{}{
+						{"b": map[string]interface{}{"$eq": 2}},
+						{"c": map[string]interface{}{"$eq": 3}},
+					}},
+				},
+			},
+		},
+		{
+			Description: "(a = ? OR b = ?) OR c = ? merged with placeholders in order",
+			Criteria: &dsc.SQLCriteria{
+				LogicalOperator: "OR",
+				Criteria: []*dsc.SQLCriterion{
+					{SQLCriteria: &dsc.SQLCriteria{
+						LogicalOperator: "OR",
+						Criteria: []*dsc.SQLCriterion{
+							{LeftOperand: "a", Operator: "=", RightOperand: "?"},
+							{LeftOperand: "b", Operator: "=", RightOperand: "?"},
+						},
+					}},
+					{LeftOperand: "c", Operator: "=", RightOperand: "?"},
+				},
+			},
+			Parameters: []interface{}{1, 2, 3},
+			Expected: map[string]interface{}{
+				"$or": []map[string]interface{}{
+					{"a": map[string]interface{}{"$eq": 1}},
+					{"b": map[string]interface{}{"$eq": 2}},
+					{"c": map[string]interface{}{"$eq": 3}},
+				},
+			},
+		},
+		{
+			Description: "a = 1 AND NOT (b = 2 AND c = 3)",
+			Criteria: &dsc.SQLCriteria{
+				LogicalOperator: "AND",
+				Criteria: []*dsc.SQLCriterion{
+					{LeftOperand: "a", Operator: "=", RightOperand: "1"},
+					{Inverse: true, SQLCriteria: &dsc.SQLCriteria{
+						LogicalOperator: "AND",
+						Criteria: []*dsc.SQLCriterion{
+							{LeftOperand: "b", Operator: "=", RightOperand: "2"},
+							{LeftOperand: "c", Operator: "=", RightOperand: "3"},
+						},
+					}},
+				},
+			},
+			Expected: map[string]interface{}{
+				"$and": []map[string]interface{}{
+					{"a": map[string]interface{}{"$eq": 1}},
+					{"$nor": []map[string]interface{}{
+						{"$and": []map[string]interface{}{
+							{"b": map[string]interface{}{"$eq": 2}},
+							{"c": map[string]interface{}{"$eq": 3}},
+						}},
+					}},
+				},
+			},
+		},
+	}
+	for _, useCase := range useCases {
+		actual, err := mgc.AsMongoCriteria(useCase.Criteria, useCase.Parameters)
+		if !assert.Nil(t, err, useCase.Description) {
+			continue
+		}
+		assert.EqualValues(t, useCase.Expected, actual, useCase.Description)
+	}
+}
+
+func TestAsMongoCriteria_Precedence(t *testing.T) {
+	var useCases = []struct {
+		Description string
+		Criteria    string
+		Parameters  []interface{}
+		Expected    map[string]interface{}
+	}{
+		{
+			Description: "AND takes precedence over following OR",
+			Criteria:    "a = 1 AND b = 2 OR c = 3",
+			Expected: map[string]interface{}{
+				"$or": []map[string]interface{}{
+					{"$and": []map[string]interface{}{
+						{"a": map[string]interface{}{"$eq": 1}},
+						{"b": map[string]interface{}{"$eq": 2}},
+					}},
+					{"c": map[string]interface{}{"$eq": 3}},
+				},
+			},
+		},
+		{
+			Description: "AND takes precedence over preceding OR with placeholders in order",
+			Criteria:    "a = ? OR b = ? AND c = ?",
+			Parameters:  []interface{}{1, 2, 3},
+			Expected: map[string]interface{}{
+				"$or": []map[string]interface{}{
+					{"a": map[string]interface{}{"$eq": 1}},
+					{"$and": []map[string]interface{}{
+						{"b": map[string]interface{}{"$eq": 2}},
+						{"c": map[string]interface{}{"$eq": 3}},
+					}},
+				},
+			},
+		},
+		{
+			Description: "parenthesized OR within AND",
+			Criteria:    "a = 1 AND (b = 2 OR c = 3)",
+			Expected: map[string]interface{}{
+				"$and": []map[string]interface{}{
+					{"a": map[string]interface{}{"$eq": 1}},
+					{"$or": []map[string]interface{}{
+						{"b": map[string]interface{}{"$eq": 2}},
+						{"c": map[string]interface{}{"$eq": 3}},
+					}},
+				},
+			},
+		},
+		{
+			Description: "mixed operators within parenthesized group",
+			Criteria:    "(a = 1 OR b = 2 AND c = 3) AND d = 4",
+			Expected: map[string]interface{}{
+				"$and": []map[string]interface{}{
+					{"$or": []map[string]interface{}{
+						{"a": map[string]interface{}{"$eq": 1}},
+						{"$and": []map[string]interface{}{
+							{"b": map[string]interface{}{"$eq": 2}},
+							{"c": map[string]interface{}{"$eq": 3}},
+						}},
+					}},
+					{"d": map[string]interface{}{"$eq": 4}},
+				},
+			},
+		},
+		{
+			Description: "BETWEEN operand AND is not logical operator",
+			Criteria:    "a BETWEEN ? AND ? AND b = 2 OR c = 3",
+			Parameters:  []interface{}{1, 5},
+			Expected: map[string]interface{}{
+				"$or": []map[string]interface{}{
+					{"$and": []map[string]interface{}{
+						{"a": map[string]interface{}{"$gte": 1, "$lte": 5}},
+						{"b": map[string]interface{}{"$eq": 2}},
+					}},
+					{"c": map[string]interface{}{"$eq": 3}},
+				},
+			},
+		},
+		{
+			Description: "quoted logical operators are ignored",
+			Criteria:    "name = 'x OR y' OR b = 2 AND c = 3",
+			Expected: map[string]interface{}{
+				"$or": []map[string]interface{}{
+					{"name": map[string]interface{}{"$eq": "x OR y"}},
+					{"$and": []map[string]interface{}{
+						{"b": map[string]interface{}{"$eq": 2}},
+						{"c": map[string]interface{}{"$eq": 3}},
+					}},
+				},
+			},
+		},
+	}
+	for _, useCase := range useCases {
+		command, err := mgc.Translate("SELECT * FROM abc WHERE "+useCase.Criteria, useCase.Parameters)
+		if !assert.Nil(t, err, useCase.Description) {
+			continue
+		}
+		assert.EqualValues(t, useCase.Expected, command.Filter, useCase.Description)
+	}
+}
+
+func TestAsMongoCriteria_Not(t *testing.T) {
+	var useCases = []struct {
+		Description string
+		Criterion   *dsc.SQLCriterion
+		Parameters  []interface{}
+		Expected    map[string]interface{}
+		HasError    bool
+	}{
+		{
+			Description: "NOT (x > 5)",
+			Criterion:   &dsc.SQLCriterion{LeftOperand: "x", Operator: ">", RightOperand: "5", Inverse: true},
+			Expected: map[string]interface{}{
+				"x": map[string]interface{}{"$not": map[string]interface{}{"$gt": 5}},
+			},
+		},
+		{
+			Description: "x NOT IN (?, ?)",
+			Criterion:   &dsc.SQLCriterion{LeftOperand: "x", Operator: "IN", RightOperand: "?,?", Inverse: true},
+			Parameters:  []interface{}{1, 2},
+			Expected: map[string]interface{}{
+				"x": map[string]interface{}{"$nin": []interface{}{1, 2}},
+			},
+		},
+		{
+			Description: "x NOT LIKE ?",
+			Criterion:   &dsc.SQLCriterion{LeftOperand: "x", Operator: "NOT LIKE", RightOperand: "?"},
+			Parameters:  []interface{}{"a%"},
+			Expected: map[string]interface{}{
+				"x": map[string]interface{}{"$not": primitive.Regex{Pattern: "^a.*$", Options: "is"}},
+			},
+		},
+		{
+			Description: "x NOT BETWEEN ? AND ?",
+			Criterion:   &dsc.SQLCriterion{LeftOperand: "x", Operator: "BETWEEN", RightOperand: "? AND ?", Inverse: true},
+			Parameters:  []interface{}{1, 3},
+			Expected: map[string]interface{}{
+				"x": map[string]interface{}{"$not": map[string]interface{}{"$gte": 1, "$lte": 3}},
+			},
+		},
+		{
+			Description: "unsupported operator",
+			Criterion:   &dsc.SQLCriterion{LeftOperand: "x", Operator: "~", RightOperand: "1", Inverse: true},
+			HasError:    true,
+		},
+	}
+	for _, useCase := range useCases {
+		criteria := &dsc.SQLCriteria{Criteria: []*dsc.SQLCriterion{useCase.Criterion}}
+		actual, err := mgc.AsMongoCriteria(criteria, useCase.Parameters)
+		if useCase.HasError {
+			if assert.NotNil(t, err, useCase.Description) {
+				assert.Contains(t, err.Error(), "NOT ~", useCase.Description)
+			}
+			continue
+		}
+		if !assert.Nil(t, err, useCase.Description) {
+			continue
+		}
+		assert.EqualValues(t, useCase.Expected, actual, useCase.Description)
+	}
+}
+
+func TestAsMongoCriteria_Array(t *testing.T) {
+	var useCases = []struct {
+		Description string
+		Criterion   *dsc.SQLCriterion
+		Parameters  []interface{}
+		Expected    map[string]interface{}
+	}{
+		{
+			Description: "? = ANY(tags)",
+			Criterion:   &dsc.SQLCriterion{LeftOperand: "?", Operator: "=", RightOperand: "ANY(tags)"},
+			Parameters:  []interface{}{"go"},
+			Expected: map[string]interface{}{
+				"tags": map[string]interface{}{"$elemMatch": map[string]interface{}{"$eq": "go"}},
+			},
+		},
+		{
+			Description: "? > ALL(scores)",
+			Criterion:   &dsc.SQLCriterion{LeftOperand: "?", Operator: ">", RightOperand: "ALL(scores)"},
+			Parameters:  []interface{}{10},
+			Expected: map[string]interface{}{
+				"scores": map[string]interface{}{
+					"$exists": true,
+					"$not":    map[string]interface{}{"$elemMatch": map[string]interface{}{"$not": map[string]interface{}{"$lt": 10}}},
+				},
+			},
+		},
+		{
+			Description: "tags CONTAINS ALL (?, ?)",
+			Criterion:   &dsc.SQLCriterion{LeftOperand: "tags", Operator: "CONTAINS ALL", RightOperand: "?, ?"},
+			Parameters:  []interface{}{"a", "b"},
+			Expected: map[string]interface{}{
+				"tags": map[string]interface{}{"$all": []interface{}{"a", "b"}},
+			},
+		},
+		{
+			Description: "ARRAY_LENGTH(tags) = 3",
+			Criterion:   &dsc.SQLCriterion{LeftOperand: "ARRAY_LENGTH(tags)", Operator: "=", RightOperand: "3"},
+			Expected: map[string]interface{}{
+				"tags": map[string]interface{}{"$size": 3},
+			},
+		},
+		{
+			Description: "ARRAY_LENGTH(tags) > ?",
+			Criterion:   &dsc.SQLCriterion{LeftOperand: "ARRAY_LENGTH(tags)", Operator: ">", RightOperand: "?"},
+			Parameters:  []interface{}{1},
+			Expected: map[string]interface{}{
+				"$expr": map[string]interface{}{"$gt": []interface{}{
+					map[string]interface{}{"$size": map[string]interface{}{"$ifNull": []interface{}{"$tags", []interface{}{}}}},
+					1,
+				}},
+			},
+		},
+		{
+			Description: "items ELEM_MATCH (qty > 5 AND price < 10)",
+			Criterion: &dsc.SQLCriterion{LeftOperand: "items", Operator: "ELEM_MATCH", SQLCriteria: &dsc.SQLCriteria{
+				LogicalOperator: "AND",
+				Criteria: []*dsc.SQLCriterion{
+					{LeftOperand: "qty", Operator: ">", RightOperand: "5"},
+					{LeftOperand: "price", Operator: "<", RightOperand: "10"},
+				},
+			}},
+			Expected: map[string]interface{}{
+				"items": map[string]interface{}{"$elemMatch": map[string]interface{}{
+					"$and": []map[string]interface{}{
+						{"qty": map[string]interface{}{"$gt": 5}},
+						{"price": map[string]interface{}{"$lt": 10}},
+					},
+				}},
+			},
+		},
+	}
+	for _, useCase := range useCases {
+		criteria := &dsc.SQLCriteria{Criteria: []*dsc.SQLCriterion{useCase.Criterion}}
+		actual, err := mgc.AsMongoCriteria(criteria, useCase.Parameters)
+		if !assert.Nil(t, err, useCase.Description) {
+			continue
+		}
+		assert.EqualValues(t, useCase.Expected, actual, useCase.Description)
+	}
+}
+
+func TestMapValue(t *testing.T) {
+	var decimal, _ = primitive.ParseDecimal128("12.50")
+	var objectID, _ = primitive.ObjectIDFromHex("5b2d6f6e1d41c8f1a8f3a1b2")
+	var useCases = []struct {
+		Description string
+		Value       string
+		Parameters  []interface{}
+		Expected    interface{}
+		HasError    bool
+	}{
+		{Description: "negative int", Value: "-3", Expected: -3},
+		{Description: "exponent float", Value: "1.5e3", Expected: 1500.0},
+		{Description: "negative float", Value: "-0.25", Expected: -0.25},
+		{Description: "null", Value: "NULL", Expected: nil},
+		{Description: "quoted string", Value: "'2024-01-01'", Expected: "2024-01-01"},
+		{Description: "object id", Value: "ObjectId('5b2d6f6e1d41c8f1a8f3a1b2')", Expected: objectID},
+		{Description: "object id placeholder", Value: "ObjectId(?)", Parameters: []interface{}{"5b2d6f6e1d41c8f1a8f3a1b2"}, Expected: objectID},
+		{Description: "iso date", Value: "ISODate('2024-01-01T10:00:00Z')", Expected: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)},
+		{Description: "timestamp", Value: "TIMESTAMP '2024-01-01 10:00:00'", Expected: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)},
+		{Description: "decimal", Value: "DECIMAL '12.50'", Expected: decimal},
+		{Description: "invalid object id", Value: "ObjectId('abc')", HasError: true},
+		{Description: "unsupported literal", Value: "abc", HasError: true},
+	}
+	for _, useCase := range useCases {
+		var index = 0
+		actual, err := mgc.MapValue(useCase.Value, useCase.Parameters, &index)
+		if useCase.HasError {
+			assert.NotNil(t, err, useCase.Description)
+			continue
+		}
+		if !assert.Nil(t, err, useCase.Description) {
+			continue
+		}
+		assert.EqualValues(t, useCase.Expected, actual, useCase.Description)
+	}
+}
@@ -1,7 +1,9 @@
 package mgc
 
 import (
+	"context"
 	"github.com/viant/dsc"
+	"go.mongodb.org/mongo-driver/bson"
 	"reflect"
 )
 
@@ -30,14 +32,23 @@ func (d *dialect) GetColumns(manager dsc.Manager, datastore, table string) ([]ds
 		return result, err
 	}
 
-	inter := db.C(table).Find(nil).Iter()
+	cursor, err := db.Collection(table).Find(context.Background(), bson.M{})
+	if err != nil {
+		return result, err
+	}
+	defer cursor.Close(context.Background())
 	var keys = make(map[string]bool)
 	record := make(map[string]interface{})
 	var types = make(map[string]reflect.Type)
 
 	var i = 0
 	//bit hacky, TODO change for map reduce and cache all update/inserts
-	for inter.Next(&record) && i < maxRecordColumnScan {
+	for i < maxRecordColumnScan && cursor.Next(context.Background()) {
+		record = make(map[string]interface{})
+		if err = cursor.Decode(&record); err != nil {
+			return result, err
+		}
+		record = normalizeDocument(record)
 		for k := range record {
 			keys[k] = true
 		}
@@ -63,11 +74,11 @@ func (d *dialect) DropTable(manager dsc.Manager, datastore string, table string)
 		return err
 	}
 	defer connection.Close()
-	session, err := asSession(connection)
+	client, err := asClient(connection)
 	if err != nil {
 		return err
 	}
-	return session.DB(datastore).C(table).DropCollection()
+	return client.Database(datastore).Collection(table).Drop(context.Background())
 }
 
 func (d *dialect) GetDatastores(manager dsc.Manager) ([]string, error) {
@@ -76,16 +87,15 @@ func (d *dialect) GetDatastores(manager dsc.Manager) ([]string, error) {
 		return nil, err
 	}
 	defer connection.Close()
-	session, err := asSession(connection)
+	client, err := asClient(connection)
 	if err != nil {
 		return nil, err
 	}
-	return session.DatabaseNames()
+	return client.ListDatabaseNames(context.Background(), bson.M{})
 }
 
 func (d *dialect) GetCurrentDatastore(manager dsc.Manager) (string, error) {
-	config := manager.Config()
-	return config.Get(dbnameKey), nil
+	return databaseName(manager.Config())
 }
 
 func (d *dialect) GetTables(manager dsc.Manager, datastore string) ([]string, error) {
@@ -98,11 +108,11 @@ func (d *dialect) GetTables(manager dsc.Manager, datastore string) ([]string, er
 	if err != nil {
 		return nil, err
 	}
-	return db.CollectionNames()
+	return db.ListCollectionNames(context.Background(), bson.M{})
 }
 
 func (d *dialect) CanPersistBatch() bool {
-	return false
+	return true
 }
 
 func (d *dialect) Ping(manager dsc.Manager) error {
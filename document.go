@@ -0,0 +1,179 @@
+package mgc
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+//asDocument returns map for document value, or false if value is not a document, ordered bson.D document is returned as its map copy
+func asDocument(value interface{}) (map[string]interface{}, bool) {
+	switch actual := value.(type) {
+	case map[string]interface{}:
+		return actual, true
+	case bson.M:
+		return actual, true
+	case bson.D:
+		var result = make(map[string]interface{})
+		for _, element := range actual {
+			result[element.Key] = element.Value
+		}
+		return result, true
+	}
+	return nil, false
+}
+
+//isArray returns true if value is a slice, ordered bson.D document is not considered an array
+func isArray(value interface{}) bool {
+	if _, ok := value.(bson.D); ok || value == nil {
+		return false
+	}
+	return reflect.TypeOf(value).Kind() == reflect.Slice
+}
+
+//asArray returns slice for array value, or false if value is not an array
+func asArray(value interface{}) ([]interface{}, bool) {
+	switch actual := value.(type) {
+	case []interface{}:
+		return actual, true
+	case bson.A:
+		return actual, true
+	}
+	return nil, false
+}
+
+//normalizeValue converts decoded bson.D, bson.M and bson.A values into maps and slices, and date times into time.Time, recursively
+func normalizeValue(value interface{}) interface{} {
+	switch actual := value.(type) {
+	case primitive.DateTime:
+		return actual.Time()
+	case bson.M, bson.D:
+		document, _ := asDocument(actual)
+		return normalizeDocument(document)
+	case map[string]interface{}:
+		return normalizeDocument(actual)
+	case bson.A, []interface{}:
+		items, _ := asArray(actual)
+		var result = make([]interface{}, len(items))
+		for i, item := range items {
+			result[i] = normalizeValue(item)
+		}
+		return result
+	}
+	return value
+}
+
+//normalizeDocument returns document with normalized values, see normalizeValue
+func normalizeDocument(document map[string]interface{}) map[string]interface{} {
+	var result = make(map[string]interface{}, len(document))
+	for k, v := range document {
+		result[k] = normalizeValue(v)
+	}
+	return result
+}
+
+//getPathValue returns value for dotted field path, numeric path fragment addresses array element
+func getPathValue(document map[string]interface{}, path string) (interface{}, bool) {
+	var value interface{} = document
+	for _, fragment := range strings.Split(path, ".") {
+		if aMap, ok := asDocument(value); ok {
+			if value, ok = aMap[fragment]; !ok {
+				return nil, false
+			}
+			continue
+		}
+		aSlice, ok := asArray(value)
+		if !ok {
+			return nil, false
+		}
+		index, err := strconv.Atoi(fragment)
+		if err != nil || index < 0 || index >= len(aSlice) {
+			return nil, false
+		}
+		value = aSlice[index]
+	}
+	return value, true
+}
+
+//setPathValue sets value for dotted field path creating intermediate documents, sibling fields are preserved
+func setPathValue(document map[string]interface{}, path string, value interface{}) {
+	var fragments = strings.Split(path, ".")
+	var parent = document
+	for i, fragment := range fragments[:len(fragments)-1] {
+		var next = parent[fragment]
+		if aSlice, ok := asArray(next); ok {
+			if index, err := strconv.Atoi(fragments[i+1]); err == nil && index >= 0 && index < len(aSlice) {
+				if i+2 == len(fragments) {
+					aSlice[index] = value
+					return
+				}
+				child, ok := asDocument(aSlice[index])
+				if !ok {
+					child = make(map[string]interface{})
+				}
+				aSlice[index] = child
+				setPathValue(child, strings.Join(fragments[i+2:], "."), value)
+				return
+			}
+		}
+		child, ok := asDocument(next)
+		if !ok {
+			child = make(map[string]interface{})
+		}
+		parent[fragment] = child
+		parent = child
+	}
+	parent[fragments[len(fragments)-1]] = value
+}
+
+//expandPaths returns document with dotted keys expanded into nested documents
+func expandPaths(record map[string]interface{}) map[string]interface{} {
+	var result = make(map[string]interface{})
+	for key, value := range record {
+		if !strings.Contains(key, ".") {
+			result[key] = value
+		}
+	}
+	for key, value := range record {
+		if strings.Contains(key, ".") {
+			setPathValue(result, key, value)
+		}
+	}
+	return result
+}
+
+//flattenDocument adds dotted keys for nested documents fields, so that nested values can be scanned into column mapped fields
+func flattenDocument(record map[string]interface{}) {
+	var documents = make(map[string]map[string]interface{})
+	for key, value := range record {
+		if document, ok := asDocument(value); ok {
+			documents[key] = document
+		}
+	}
+	for key, document := range documents {
+		flattenInto(record, key, document)
+	}
+}
+
+func flattenInto(record map[string]interface{}, prefix string, document map[string]interface{}) {
+	for key, value := range document {
+		var path = prefix + "." + key
+		record[path] = value
+		if nested, ok := asDocument(value); ok {
+			flattenInto(record, path, nested)
+		}
+	}
+}
+
+//projectionPath returns path usable in find projection, array element paths are truncated to the array field
+func projectionPath(path string) string {
+	var fragments = strings.Split(path, ".")
+	for i, fragment := range fragments {
+		if _, err := strconv.Atoi(fragment); err == nil && i > 0 {
+			return strings.Join(fragments[:i], ".")
+		}
+	}
+	return path
+}
@@ -0,0 +1,131 @@
+package mgc
+
+import (
+	"encoding/json"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"regexp"
+	"strings"
+)
+
+const (
+	explainPlanColumn          = "plan"
+	explainStageColumn         = "stage"
+	explainIndexColumn         = "index"
+	explainDocsExaminedColumn  = "docsExamined"
+	explainKeysExaminedColumn  = "keysExamined"
+	explainReturnedColumn      = "returned"
+	explainExecutionTimeColumn = "executionTimeMillis"
+)
+
+var explainExpr = regexp.MustCompile(`(?is)^\s*EXPLAIN\s+`)
+
+//explainColumns represents EXPLAIN result columns
+var explainColumns = []string{
+	explainStageColumn,
+	explainIndexColumn,
+	explainDocsExaminedColumn,
+	explainKeysExaminedColumn,
+	explainReturnedColumn,
+	explainExecutionTimeColumn,
+	explainPlanColumn,
+}
+
+//extractExplain returns SQL without EXPLAIN prefix and true, or unchanged SQL and false if SQL is not EXPLAIN statement
+func extractExplain(SQL string) (string, bool) {
+	if location := explainExpr.FindStringIndex(SQL); location != nil {
+		return SQL[location[1]:], true
+	}
+	return SQL, false
+}
+
+//explainCommand returns explain server command with executionStats verbosity for query command
+func (c *Command) explainCommand() (bson.D, error) {
+	var filter = c.filterDocument()
+	var command bson.D
+	switch c.Type {
+	case commandFind:
+		command = bson.D{{Key: "find", Value: c.Collection}, {Key: "filter", Value: filter}}
+		if c.Projection != nil {
+			command = append(command, bson.E{Key: "projection", Value: c.Projection})
+		}
+		if len(c.Sort) > 0 {
+			command = append(command, bson.E{Key: "sort", Value: asSortDocument(c.Sort)})
+		}
+		if c.Skip > 0 {
+			command = append(command, bson.E{Key: "skip", Value: c.Skip})
+		}
+		if c.Limit > 0 {
+			command = append(command, bson.E{Key: "limit", Value: c.Limit})
+		}
+	case commandAggregate:
+		command = bson.D{{Key: "aggregate", Value: c.Collection}, {Key: "pipeline", Value: c.Pipeline}, {Key: "cursor", Value: bson.M{}}}
+	case commandDistinct:
+		command = bson.D{{Key: "distinct", Value: c.Collection}, {Key: "key", Value: c.Distinct}, {Key: "query", Value: filter}}
+	default:
+		return nil, fmt.Errorf("unsupported EXPLAIN command type: %v", c.Type)
+	}
+	return bson.D{{Key: "explain", Value: command}, {Key: "verbosity", Value: "executionStats"}}, nil
+}
+
+//explainSection returns explain output section, aggregation explain may nest it in the first $cursor stage
+func explainSection(explain map[string]interface{}, name string) map[string]interface{} {
+	if section, ok := asDocument(explain[name]); ok {
+		return section
+	}
+	if stages, ok := asArray(explain["stages"]); ok && len(stages) > 0 {
+		if stage, ok := asDocument(stages[0]); ok {
+			if cursor, ok := asDocument(stage["$cursor"]); ok {
+				return explainSection(cursor, name)
+			}
+		}
+	}
+	return nil
+}
+
+//planIndexes returns index names used by plan stages
+func planIndexes(plan map[string]interface{}) []string {
+	var result = make([]string, 0)
+	if name, ok := plan["indexName"].(string); ok {
+		result = append(result, name)
+	}
+	if inputStage, ok := asDocument(plan["inputStage"]); ok {
+		result = append(result, planIndexes(inputStage)...)
+	}
+	if inputStages, ok := asArray(plan["inputStages"]); ok {
+		for _, item := range inputStages {
+			if inputStage, ok := asDocument(item); ok {
+				result = append(result, planIndexes(inputStage)...)
+			}
+		}
+	}
+	return result
+}
+
+//asExplainRecord converts explain command output into EXPLAIN result row
+func asExplainRecord(explain map[string]interface{}) (map[string]interface{}, error) {
+	var result = map[string]interface{}{}
+	for _, column := range explainColumns {
+		result[column] = nil
+	}
+	if planner := explainSection(explain, "queryPlanner"); planner != nil {
+		if plan, ok := asDocument(planner["winningPlan"]); ok {
+			result[explainStageColumn] = plan["stage"]
+			if indexes := planIndexes(plan); len(indexes) > 0 {
+				result[explainIndexColumn] = strings.Join(indexes, ",")
+			}
+			encoded, err := json.Marshal(plan)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode winning plan, %v", err)
+			}
+			result[explainPlanColumn] = string(encoded)
+		}
+	}
+	if stats := explainSection(explain, "executionStats"); stats != nil {
+		result[explainDocsExaminedColumn] = stats["totalDocsExamined"]
+		result[explainKeysExaminedColumn] = stats["totalKeysExamined"]
+		result[explainReturnedColumn] = stats["nReturned"]
+		result[explainExecutionTimeColumn] = stats["executionTimeMillis"]
+	}
+	return result, nil
+}
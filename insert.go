@@ -0,0 +1,141 @@
+package mgc
+
+import (
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"regexp"
+	"strings"
+)
+
+//affected rows reported for upsert, following MySQL INSERT ... ON DUPLICATE KEY UPDATE convention
+const (
+	upsertInserted = 1
+	upsertUpdated  = 2
+)
+
+var insertValuesExpr = regexp.MustCompile(`(?is)^\s*(INSERT\s+INTO\s+[\w.]+\s*\([^)]*\)\s*VALUES)\s*(.+?)\s*$`)
+var upsertExpr = regexp.MustCompile(`(?is)^\s*UPSERT\s+INTO\s+`)
+var onDuplicateKeyExpr = regexp.MustCompile(`(?is)\s+ON\s+DUPLICATE\s+KEY\s+UPDATE\s+(.+)$`)
+var valuesReferenceExpr = regexp.MustCompile(`(?is)^\s*([\w.]+)\s*=\s*VALUES\s*\(\s*([\w.]+)\s*\)\s*$`)
+
+//extractInsertTuples returns INSERT statement prefix with VALUES keyword and its values tuples, or nil if SQL is not multi-row INSERT
+func extractInsertTuples(SQL string) (string, []string) {
+	matched := insertValuesExpr.FindStringSubmatch(SQL)
+	if len(matched) == 0 {
+		return "", nil
+	}
+	var tuples = splitTopLevel(matched[2], ',')
+	if len(tuples) < 2 {
+		return "", nil
+	}
+	for i, tuple := range tuples {
+		tuple = strings.TrimSpace(tuple)
+		if !strings.HasPrefix(tuple, "(") || !strings.HasSuffix(tuple, ")") {
+			return "", nil
+		}
+		tuples[i] = tuple
+	}
+	return matched[1], tuples
+}
+
+//isUpsert returns true for UPSERT INTO or INSERT ... ON DUPLICATE KEY UPDATE statement
+func isUpsert(SQL string) bool {
+	return upsertExpr.MatchString(SQL) || onDuplicateKeyExpr.MatchString(SQL)
+}
+
+//upsertDocument returns single inserted document of insert command
+func upsertDocument(command *Command) (map[string]interface{}, error) {
+	if len(command.Documents) != 1 {
+		return nil, fmt.Errorf("expected one upserted document, but had %v", len(command.Documents))
+	}
+	document, ok := asDocument(command.Documents[0])
+	if !ok {
+		return nil, fmt.Errorf("unsupported upserted document type: %T", command.Documents[0])
+	}
+	if _, has := document[mongoIDKey]; !has {
+		return nil, fmt.Errorf("upserted document into %v has no key column value", command.Collection)
+	}
+	return document, nil
+}
+
+//translateUpsert translates UPSERT INTO into upsert merging listed fields into the existing document, fields not listed are kept,
+//UPSERT INTO listing only the key column inserts missing document and leaves existing one unchanged,
+//INSERT ... ON DUPLICATE KEY UPDATE is translated into upsert with inserted document and update assignments applied to the existing document on duplicate key
+func (c *config) translateUpsert(SQL string, parameters []interface{}) (*Command, error) {
+	var assignments = ""
+	if location := onDuplicateKeyExpr.FindStringSubmatchIndex(SQL); location != nil {
+		assignments = SQL[location[2]:location[3]]
+		SQL = SQL[:location[0]]
+	}
+	SQL = upsertExpr.ReplaceAllString(SQL, "INSERT INTO ")
+	var insertParameterCount = countPlaceholders(SQL)
+	if insertParameterCount > len(parameters) {
+		return nil, fmt.Errorf("array out of bound, %v %v", insertParameterCount, len(parameters))
+	}
+	command, err := c.translateDML(SQL, parameters[:insertParameterCount])
+	if err != nil {
+		return nil, err
+	}
+	document, err := upsertDocument(command)
+	if err != nil {
+		return nil, err
+	}
+	var result = &Command{Type: commandUpsert, Collection: command.Collection, Filter: bson.M{mongoIDKey: document[mongoIDKey]}}
+	if assignments == "" {
+		var fields = bson.M{}
+		for k, v := range document {
+			if k != mongoIDKey {
+				fields[k] = v
+			}
+		}
+		if len(fields) == 0 {
+			result.Update = bson.M{"$setOnInsert": bson.M{mongoIDKey: document[mongoIDKey]}}
+			return result, nil
+		}
+		result.Update = bson.M{"$set": fields}
+		return result, nil
+	}
+	if result.Update, err = c.duplicateKeyUpdate(command.Collection, document, assignments, parameters[insertParameterCount:]); err != nil {
+		return nil, err
+	}
+	result.Documents = command.Documents
+	return result, nil
+}
+
+//duplicateKeyUpdate returns update document for ON DUPLICATE KEY UPDATE assignments, VALUES(column) refers to inserted document value
+func (c *config) duplicateKeyUpdate(table string, document map[string]interface{}, assignments string, parameters []interface{}) (bson.M, error) {
+	var values = make(map[string]interface{})
+	var plain = make([]string, 0)
+	for _, assignment := range splitTopLevel(assignments, ',') {
+		if matched := valuesReferenceExpr.FindStringSubmatch(assignment); len(matched) > 0 {
+			value, _ := getPathValue(document, matched[2])
+			values[matched[1]] = value
+			continue
+		}
+		plain = append(plain, strings.TrimSpace(assignment))
+	}
+	var result = bson.M{}
+	if len(plain) > 0 {
+		command, err := c.translateDML("UPDATE "+table+" SET "+strings.Join(plain, ", "), parameters)
+		if err != nil {
+			return nil, err
+		}
+		if command.Merge {
+			record, _ := asDocument(command.Update)
+			result = asUpdateDocument(record, nil)
+		} else if update, ok := command.Update.(bson.M); ok {
+			result = update
+		}
+	}
+	if len(values) > 0 {
+		set, ok := asDocument(result["$set"])
+		if !ok {
+			set = make(map[string]interface{})
+		}
+		for k, v := range values {
+			set[k] = v
+		}
+		result["$set"] = set
+	}
+	return result, nil
+}
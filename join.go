@@ -0,0 +1,195 @@
+package mgc
+
+import (
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"regexp"
+	"strings"
+)
+
+var joinExpr = regexp.MustCompile(`(?i)\s+(?:(INNER|LEFT)(?:\s+OUTER)?\s+)?JOIN\s+`)
+var joinSourceExpr = regexp.MustCompile(`(?i)^(\w+)(?:\s+(?:AS\s+)?(\w+))?$`)
+var joinConditionExpr = regexp.MustCompile(`(?i)^(\w+)(?:\s+(?:AS\s+)?(\w+))?\s+ON\s+([\w.]+)\s*=\s*([\w.]+)$`)
+
+//join represents equi join
+type join struct {
+	Type         string //INNER or LEFT
+	Table        string
+	Alias        string
+	LocalField   string //local field path
+	ForeignField string //joined collection field
+}
+
+//joinQuery represents query with joined collections, joined documents are stored under join alias
+type joinQuery struct {
+	Table string
+	Alias string
+	Joins []*join
+	Items []*selectItem
+}
+
+//isJoinAlias returns true if alias refers to joined collection
+func (q *joinQuery) isJoinAlias(alias string) bool {
+	for _, join := range q.Joins {
+		if join.Alias == alias {
+			return true
+		}
+	}
+	return false
+}
+
+//path returns document field path for alias qualified column
+func (q *joinQuery) path(column string) string {
+	if index := strings.Index(column, "."); index != -1 && column[:index] == q.Alias {
+		return column[index+1:]
+	}
+	return column
+}
+
+//outputName returns flattened output column name, columns of joined collections are prefixed with join alias
+func (q *joinQuery) outputName(item *selectItem) string {
+	if item.Alias != "" {
+		return item.Alias
+	}
+	var path = q.path(item.Expression)
+	if index := strings.Index(path, "."); index != -1 && q.isJoinAlias(path[:index]) {
+		return path[:index] + "_" + path[index+1:]
+	}
+	return path
+}
+
+//Columns returns output column names
+func (q *joinQuery) Columns() []string {
+	var result = make([]string, 0)
+	for _, item := range q.Items {
+		if item.Expression == "*" {
+			return nil
+		}
+		result = append(result, q.outputName(item))
+	}
+	return result
+}
+
+//Flatten moves joined documents fields into alias prefixed record fields, it is used when all fields are selected
+func (q *joinQuery) Flatten(record map[string]interface{}) {
+	for _, join := range q.Joins {
+		joined, ok := record[join.Alias]
+		if !ok {
+			continue
+		}
+		delete(record, join.Alias)
+		if document, ok := asDocument(joined); ok {
+			for k, v := range document {
+				record[join.Alias+"_"+k] = v
+			}
+		}
+	}
+}
+
+//Pipeline returns $lookup based aggregation pipeline, criteria keys are expected to be already resolved to document paths
+func (q *joinQuery) Pipeline(criteria map[string]interface{}, options *queryOptions) []interface{} {
+	var pipeline = make([]interface{}, 0)
+	for _, join := range q.Joins {
+		pipeline = append(pipeline, bson.M{"$lookup": bson.M{
+			"from":         join.Table,
+			"localField":   join.LocalField,
+			"foreignField": join.ForeignField,
+			"as":           join.Alias,
+		}})
+		pipeline = append(pipeline, bson.M{"$unwind": bson.M{
+			"path":                       "$" + join.Alias,
+			"preserveNullAndEmptyArrays": join.Type == "LEFT",
+		}})
+	}
+	if len(criteria) > 0 {
+		pipeline = append(pipeline, bson.M{"$match": criteria})
+	}
+	pipeline = append(pipeline, asPipelineOptions(options, func(name string) string {
+		for _, item := range q.Items {
+			if item.Alias == name {
+				name = item.Expression
+			}
+		}
+		return q.path(name)
+	})...)
+	if columns := q.Columns(); columns != nil {
+		var projection = bson.D{{Key: mongoIDKey, Value: 0}}
+		for i, item := range q.Items {
+			projection = append(projection, bson.E{Key: columns[i], Value: "$" + q.path(item.Expression)})
+		}
+		pipeline = append(pipeline, bson.M{"$project": projection})
+	}
+	return pipeline
+}
+
+//parseJoin parses 'table [AS] alias ON a.x = b.y' join fragment
+func (q *joinQuery) parseJoin(joinType, fragment string) (*join, error) {
+	matched := joinConditionExpr.FindStringSubmatch(strings.TrimSpace(fragment))
+	if len(matched) != 5 {
+		return nil, fmt.Errorf("unsupported JOIN: %v", fragment)
+	}
+	var result = &join{Type: strings.ToUpper(joinType), Table: matched[1], Alias: matched[2]}
+	if result.Type == "" {
+		result.Type = "INNER"
+	}
+	if result.Alias == "" {
+		result.Alias = result.Table
+	}
+	var prefix = result.Alias + "."
+	var left, right = matched[3], matched[4]
+	if strings.HasPrefix(left, prefix) {
+		left, right = right, left
+	}
+	if !strings.HasPrefix(right, prefix) || strings.HasPrefix(left, prefix) {
+		return nil, fmt.Errorf("JOIN condition has to compare %v column with other collection column: %v", result.Alias, fragment)
+	}
+	result.LocalField = q.path(left)
+	result.ForeignField = strings.TrimPrefix(right, prefix)
+	return result, nil
+}
+
+//extractJoinQuery returns join query with SQL rewritten to select all fields from the base collection, or nil if SQL does not use JOIN
+func extractJoinQuery(SQL string) (string, *joinQuery, error) {
+	items, fromIndex, err := parseSelectItems(SQL)
+	if err != nil {
+		return "", nil, err
+	}
+	var fromEnd = len(SQL)
+	for _, keyword := range []string{"WHERE", "GROUP", "HAVING"} {
+		if index := indexKeyword(SQL, keyword); index != -1 && index < fromEnd {
+			fromEnd = index
+		}
+	}
+	var from = SQL[fromIndex+len("FROM") : fromEnd]
+	var joins = joinExpr.FindAllStringSubmatchIndex(from, -1)
+	if len(joins) == 0 {
+		return SQL, nil, nil
+	}
+	if isAggregate(items) || distinctExpr.MatchString(SQL) || indexKeyword(SQL, "GROUP BY") != -1 || indexKeyword(SQL, "HAVING") != -1 {
+		return "", nil, fmt.Errorf("aggregate queries with JOIN are not supported: %v", SQL)
+	}
+	source := joinSourceExpr.FindStringSubmatch(strings.TrimSpace(from[:joins[0][0]]))
+	if len(source) != 3 {
+		return "", nil, fmt.Errorf("unsupported FROM: %v", from)
+	}
+	var result = &joinQuery{Table: source[1], Alias: source[2], Items: items}
+	if result.Alias == "" {
+		result.Alias = result.Table
+	}
+	for i, indexes := range joins {
+		var end = len(from)
+		if i+1 < len(joins) {
+			end = joins[i+1][0]
+		}
+		var joinType = ""
+		if indexes[2] != -1 {
+			joinType = from[indexes[2]:indexes[3]]
+		}
+		join, err := result.parseJoin(joinType, from[indexes[1]:end])
+		if err != nil {
+			return "", nil, err
+		}
+		result.Joins = append(result.Joins, join)
+	}
+	return strings.TrimSpace("SELECT * FROM " + result.Table + " " + SQL[fromEnd:]), result, nil
+}
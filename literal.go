@@ -0,0 +1,106 @@
+package mgc
+
+import (
+	"fmt"
+	"github.com/viant/toolbox"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var typedFunctionLiteralExpr = regexp.MustCompile(`(?is)^(OBJECTID|ISODATE)\s*\(\s*(.*?)\s*\)$`)
+var typedPrefixLiteralExpr = regexp.MustCompile(`(?is)^(TIMESTAMP|DATE|DECIMAL)\s+(.+)$`)
+var intLiteralExpr = regexp.MustCompile(`^[-+]?\d+$`)
+var floatLiteralExpr = regexp.MustCompile(`^[-+]?(\d+\.?\d*|\.\d+)([eE][-+]?\d+)?$`)
+
+//timeLayouts represents supported ISODate and TIMESTAMP literal layouts
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+//typedLiteral returns type name and its operand for ObjectId('hex'), ISODate('...'), TIMESTAMP '...', DATE '...' or DECIMAL '...' literal
+func typedLiteral(literal string) (string, string, bool) {
+	literal = strings.TrimSpace(literal)
+	if matched := typedFunctionLiteralExpr.FindStringSubmatch(literal); len(matched) > 0 {
+		return strings.ToUpper(matched[1]), matched[2], true
+	}
+	if matched := typedPrefixLiteralExpr.FindStringSubmatch(literal); len(matched) > 0 {
+		return strings.ToUpper(matched[1]), strings.TrimSpace(matched[2]), true
+	}
+	return "", "", false
+}
+
+//asTypedValue converts resolved typed literal operand into corresponding bson type
+func asTypedValue(typeName string, value interface{}) (interface{}, error) {
+	switch typeName {
+	case "OBJECTID":
+		if id, ok := value.(primitive.ObjectID); ok {
+			return id, nil
+		}
+		hex := toolbox.AsString(value)
+		id, err := primitive.ObjectIDFromHex(hex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ObjectId: %v", hex)
+		}
+		return id, nil
+	case "ISODATE", "TIMESTAMP", "DATE":
+		switch actual := value.(type) {
+		case time.Time:
+			return actual, nil
+		case *time.Time:
+			return *actual, nil
+		}
+		return asTime(toolbox.AsString(value))
+	case "DECIMAL":
+		if decimal, ok := value.(primitive.Decimal128); ok {
+			return decimal, nil
+		}
+		decimal, err := primitive.ParseDecimal128(toolbox.AsString(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid DECIMAL: %v, %v", value, err)
+		}
+		return decimal, nil
+	}
+	return nil, fmt.Errorf("unsupported literal type: %v", typeName)
+}
+
+//asTime parses date or timestamp text
+func asTime(text string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if result, err := time.Parse(layout, text); err == nil {
+			return result, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date: %v", text)
+}
+
+//asLiteralValue converts unquoted SQL literal into its value: NULL, boolean, integer or float with optional sign and exponent
+func asLiteralValue(literal string) (interface{}, error) {
+	switch strings.ToLower(literal) {
+	case "null":
+		return nil, nil
+	case "true", "false":
+		return toolbox.AsBoolean(literal), nil
+	}
+	if intLiteralExpr.MatchString(literal) {
+		value, err := toolbox.ToInt(literal)
+		if err != nil {
+			return nil, err
+		}
+		return value, nil
+	}
+	if floatLiteralExpr.MatchString(literal) {
+		value, err := strconv.ParseFloat(literal, 64)
+		if err != nil {
+			return nil, err
+		}
+		return value, nil
+	}
+	return nil, fmt.Errorf("unsupported literal: %v", literal)
+}
@@ -1,22 +1,35 @@
 package mgc
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	mgo "github.com/globalsign/mgo"
 	"github.com/viant/dsc"
-	"github.com/viant/toolbox"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"strings"
 )
 
 const (
-	pkColumnKey = "keyColumn"
-	mongoIDKey  = "_id"
+	pkColumnKey        = "keyColumn"
+	missingAsNullKey   = "missingAsNull"
+	flattenNestedKey   = "flattenNested"
+	keyTypeKey         = "keyType"
+	replaceOnUpdateKey = "replaceOnUpdate"
+	updateAffectedKey  = "updateAffected"
+	matchedAffected    = "matched"
+	modifiedAffected   = "modified"
+	objectIDKeyType    = "ObjectId"
+	mongoIDKey         = "_id"
 )
 
 type config struct {
 	*dsc.Config
-	keyColumn string
-	dbName    string
+	keyColumn     string
+	dbName        string
+	missingAsNull bool
+	flattenNested bool //SELECT * records get dotted keys for nested documents fields
 }
 
 type manager struct {
@@ -24,125 +37,243 @@ type manager struct {
 	config *config
 }
 
-func (m *manager) getKeyColumn(table string) string {
-	if keyColumn := m.config.GetString(table+"."+pkColumnKey, ""); keyColumn != "" {
-		return keyColumn
+func (m *manager) ExecuteOnConnection(connection dsc.Connection, sql string, sqlParameters []interface{}) (result sql.Result, err error) {
+	dsc.Logf("[%v]:%v, %v\n", m.config.dbName, sql, sqlParameters)
+	db, err := asDatabase(connection)
+	if err != nil {
+		return nil, err
+	}
+	command, err := m.config.translateDML(sql, sqlParameters)
+	if err != nil {
+		return nil, err
+	}
+	var affectedRecords int
+	var insertedID interface{}
+	if transaction := asTransaction(connection); transaction != nil {
+		affectedRecords, insertedID, err = m.executeInTransaction(transaction, db, command)
+	} else {
+		affectedRecords, insertedID, err = m.execute(context.Background(), db, command)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to modify %v, %v", command.Collection, err)
 	}
-	return m.config.keyColumn
+	return &Result{Result: dsc.NewSQLResult(int64(affectedRecords), asLastInsertID(insertedID)), insertedID: insertedID}, nil
 }
 
-func (m *manager) updatePKIfNeeded(table string, record map[string]interface{}, replace bool) {
-	if _, has := record[mongoIDKey]; has {
-		return
+//Result represents execution result, LastInsertId returns numeric _id only, InsertedID returns any _id, i.e. ObjectId generated by the driver
+type Result struct {
+	sql.Result
+	insertedID interface{}
+}
+
+//InsertedID returns _id of the last inserted or upserted document, or nil
+func (r *Result) InsertedID() interface{} {
+	return r.insertedID
+}
+
+//asLastInsertID returns numeric inserted document _id, or 0
+func asLastInsertID(id interface{}) int64 {
+	switch actual := id.(type) {
+	case int:
+		return int64(actual)
+	case int32:
+		return int64(actual)
+	case int64:
+		return actual
 	}
-	keyColumn := m.getKeyColumn(table)
-	if id, has := record[keyColumn]; has {
-		record[mongoIDKey] = id
-		if replace {
-			delete(record, keyColumn)
-		}
+	return 0
+}
+
+//affectedCount returns matched or modified documents count depending on configured updateAffected mode
+func (m *manager) affectedCount(result *mongo.UpdateResult) int {
+	if strings.EqualFold(m.config.GetString(updateAffectedKey, matchedAffected), modifiedAffected) {
+		return int(result.ModifiedCount)
 	}
-	return
+	return int(result.MatchedCount)
 }
 
-func (m *manager) runInsert(db *mgo.Database, statement *dsc.DmlStatement, sqlParameters []interface{}) (err error) {
-	parameters := toolbox.NewSliceIterator(sqlParameters)
-	var record map[string]interface{}
-	if record, err = statement.ColumnValueMap(parameters); err != nil {
-		return err
+//execute runs insert, upsert, update, remove or truncate command, it returns affected documents count and _id of the last inserted document
+func (m *manager) execute(ctx context.Context, db *mongo.Database, command *Command) (int, interface{}, error) {
+	collection := db.Collection(command.Collection)
+	switch command.Type {
+	case commandInsert:
+		result, err := collection.InsertMany(ctx, command.Documents)
+		if err != nil {
+			return 0, nil, err
+		}
+		var insertedID interface{}
+		if len(result.InsertedIDs) > 0 {
+			insertedID = result.InsertedIDs[len(result.InsertedIDs)-1]
+		}
+		return len(command.Documents), insertedID, nil
+	case commandUpsert:
+		if len(command.Documents) > 0 {
+			return m.insertOrUpdate(ctx, collection, command)
+		}
+		result, err := collection.UpdateOne(ctx, command.Filter, command.Update, options.Update().SetUpsert(true))
+		if err != nil {
+			return 0, nil, err
+		}
+		if result.UpsertedID != nil {
+			return upsertInserted, result.UpsertedID, nil
+		}
+		return upsertUpdated, nil, nil
+	case commandUpdate:
+		if command.Merge {
+			affected, err := m.merge(ctx, collection, command)
+			return affected, nil, err
+		}
+		result, err := collection.UpdateMany(ctx, command.filterDocument(), command.Update)
+		if err != nil {
+			return 0, nil, err
+		}
+		return m.affectedCount(result), nil, nil
+	case commandRemove:
+		result, err := collection.DeleteMany(ctx, command.filterDocument())
+		if err != nil {
+			return 0, nil, err
+		}
+		return int(result.DeletedCount), nil, nil
+	case commandTruncate:
+		affected, err := m.truncate(ctx, collection)
+		return affected, nil, err
 	}
-	m.updatePKIfNeeded(statement.Table, record, false)
-	collection := db.C(statement.Table)
-	return collection.Insert(record)
+	return 0, nil, fmt.Errorf("unsupported command type: %v", command.Type)
 }
 
-func (m *manager) runUpdate(db *mgo.Database, statement *dsc.DmlStatement, sqlParameters []interface{}) (err error) {
-	parameters := toolbox.NewSliceIterator(sqlParameters)
-	var record map[string]interface{}
-	if record, err = statement.ColumnValueMap(parameters); err != nil {
-		return err
+//insertOrUpdate inserts upsert command document, on duplicate key the document matched by the filter is updated,
+//insert is retried once if the duplicated document was removed before the update
+func (m *manager) insertOrUpdate(ctx context.Context, collection *mongo.Collection, command *Command) (int, interface{}, error) {
+	var insertErr error
+	for i := 0; i < 2; i++ {
+		inserted, err := collection.InsertOne(ctx, command.Documents[0])
+		if err == nil {
+			return upsertInserted, inserted.InsertedID, nil
+		}
+		if insertErr = err; !mongo.IsDuplicateKeyError(err) {
+			return 0, nil, err
+		}
+		result, err := collection.UpdateOne(ctx, command.Filter, command.Update)
+		if err != nil {
+			return 0, nil, err
+		}
+		if result.MatchedCount > 0 {
+			return upsertUpdated, nil, nil
+		}
 	}
-	criteria, err := m.criteria(statement.BaseStatement, parameters)
+	return 0, nil, insertErr
+}
+
+//collectionOptions returns collection options, i.e. capped size, validator or collation, as listed by listCollections command, or false if collection does not exist
+func collectionOptions(ctx context.Context, collection *mongo.Collection) (bson.D, bool, error) {
+	cursor, err := collection.Database().ListCollections(ctx, bson.M{"name": collection.Name()})
 	if err != nil {
-		return err
+		return nil, false, err
 	}
-	m.updatePKIfNeeded(statement.Table, criteria, true)
-	collection := db.C(statement.Table)
-	query := collection.Find(criteria)
-	previous := map[string]interface{}{}
-	if query.Iter().Next(&previous) {
-		for k, v := range previous {
-			if _, has := record[k]; has {
-				continue
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var info = bson.D{}
+		if err = cursor.Decode(&info); err != nil {
+			return nil, false, err
+		}
+		var result = bson.D{}
+		for _, element := range info {
+			if options, ok := element.Value.(bson.D); ok && element.Key == "options" {
+				result = options
 			}
-			record[k] = v
 		}
+		return result, true, nil
 	}
-	return collection.Update(criteria, record)
+	return nil, false, cursor.Err()
 }
 
-func (m *manager) criteria(statement *dsc.BaseStatement, parameters toolbox.Iterator) (map[string]interface{}, error) {
-	criteriaValues, err := statement.CriteriaValues(parameters)
+//collectionIndexes returns collection index specifications, except _id index, as listed by listIndexes command
+func collectionIndexes(ctx context.Context, collection *mongo.Collection) ([]bson.D, error) {
+	cursor, err := collection.Indexes().List(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return AsMongoCriteria(statement.SQLCriteria, criteriaValues)
-
+	defer cursor.Close(ctx)
+	var result = make([]bson.D, 0)
+	for cursor.Next(ctx) {
+		var index = bson.D{}
+		if err = cursor.Decode(&index); err != nil {
+			return nil, err
+		}
+		var indexSpec = bson.D{}
+		for _, element := range index {
+			if element.Key != "v" && element.Key != "ns" {
+				indexSpec = append(indexSpec, element)
+			}
+		}
+		if spec, _ := asDocument(indexSpec); spec["name"] != "_id_" {
+			result = append(result, indexSpec)
+		}
+	}
+	return result, cursor.Err()
 }
 
-func (m *manager) runDelete(db *mgo.Database, statement *dsc.DmlStatement, sqlParameters []interface{}) (affected int, err error) {
-	collection := db.C(statement.Table)
-	if len(statement.Criteria) == 0 {
-
-		var count, _ = collection.Count()
-		if count == 0 {
-			return 0, nil
-		}
-		return count, db.C(statement.Table).DropCollection()
+//truncate drops and recreates collection with its options and indexes, it returns removed documents count, missing collection is not created
+func (m *manager) truncate(ctx context.Context, collection *mongo.Collection) (int, error) {
+	createOptions, exists, err := collectionOptions(ctx, collection)
+	if err != nil || !exists {
+		return 0, err
 	}
-	parameters := toolbox.NewSliceIterator(sqlParameters)
-	criteria, err := m.criteria(statement.BaseStatement, parameters)
+	count, err := collection.CountDocuments(ctx, bson.M{})
 	if err != nil {
 		return 0, err
 	}
-	m.updatePKIfNeeded(statement.Table, criteria, true)
-	info, err := collection.RemoveAll(criteria)
+	indexes, err := collectionIndexes(ctx, collection)
 	if err != nil {
 		return 0, err
 	}
-	return info.Removed, nil
+	if err = collection.Drop(ctx); err != nil {
+		return 0, err
+	}
+	var create = append(bson.D{{Key: "create", Value: collection.Name()}}, createOptions...)
+	if err = collection.Database().RunCommand(ctx, create).Err(); err != nil {
+		return 0, fmt.Errorf("failed to recreate collection %v, %v", collection.Name(), err)
+	}
+	if len(indexes) == 0 {
+		return int(count), nil
+	}
+	if err = collection.Database().RunCommand(ctx, bson.D{{Key: "createIndexes", Value: collection.Name()}, {Key: "indexes", Value: indexes}}).Err(); err != nil {
+		return 0, fmt.Errorf("failed to recreate indexes of %v, %v", collection.Name(), err)
+	}
+	return int(count), nil
 }
 
-func (m *manager) ExecuteOnConnection(connection dsc.Connection, sql string, sqlParameters []interface{}) (result sql.Result, err error) {
-	dsc.Logf("[%v]:%v, %v\n", m.config.dbName, sql, sqlParameters)
-	db, err := asDatabase(connection)
-	if err != nil {
-		return nil, err
-	}
-	parser := dsc.NewDmlParser()
-	statement, err := parser.Parse(sql)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse %v due to %v", sql, err)
+//merge applies update fields to the matched document and replaces it, it returns affected documents count
+func (m *manager) merge(ctx context.Context, collection *mongo.Collection, command *Command) (int, error) {
+	document := map[string]interface{}{}
+	if err := collection.FindOne(ctx, command.filterDocument()).Decode(&document); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return 0, nil
+		}
+		return 0, err
 	}
-	var affectedRecords = 1
-	switch statement.Type {
-	case "INSERT":
-		err = m.runInsert(db, statement, sqlParameters)
-	case "UPDATE":
-		err = m.runUpdate(db, statement, sqlParameters)
-	case "DELETE":
-		affectedRecords, err = m.runDelete(db, statement, sqlParameters)
+	document = normalizeDocument(document)
+	if record, ok := asDocument(command.Update); ok {
+		for k, v := range record {
+			setPathValue(document, k, v)
+		}
 	}
+	result, err := collection.ReplaceOne(ctx, bson.M{mongoIDKey: document[mongoIDKey]}, document)
 	if err != nil {
-		return nil, fmt.Errorf("failed to modify %v, %v", statement.Table, err)
+		return 0, err
 	}
-	return dsc.NewSQLResult(int64(affectedRecords), 0), nil
+	return int(result.MatchedCount), nil
 }
 
-func (m *manager) enrichRecordIfNeeded(statement *dsc.QueryStatement, record map[string]interface{}) []string {
+func enrichRecordIfNeeded(statement *dsc.QueryStatement, record map[string]interface{}) []string {
 	var columns = make([]string, 0)
 	for _, column := range statement.Columns {
 		var name = column.Name
+		if _, has := record[name]; !has && strings.Contains(name, ".") {
+			if value, ok := getPathValue(record, name); ok {
+				record[name] = value
+			}
+		}
 		if column.Alias != "" {
 			if value, ok := record[name]; ok {
 				delete(record, name)
@@ -162,27 +293,81 @@ func (m *manager) ReadAllOnWithHandlerOnConnection(connection dsc.Connection, SQ
 	if err != nil {
 		return err
 	}
-	parser := dsc.NewQueryParser()
-	statement, err := parser.Parse(SQL)
+	command, err := m.config.translateQuery(SQL, SQLParameters)
 	if err != nil {
-		return fmt.Errorf("failed to parse statement %v, %v", SQL, err)
+		return err
 	}
-	parameters := toolbox.NewSliceIterator(SQLParameters)
-	criteria, err := m.criteria(statement.BaseStatement, parameters)
+	return m.read(asContext(connection), db, command, readingHandler)
+}
+
+//read runs find, distinct or aggregate command and passes results to reading handler
+func (m *manager) read(ctx context.Context, db *mongo.Database, command *Command, readingHandler func(scanner dsc.Scanner) (toContinue bool, err error)) error {
+	if command.Explain {
+		return m.explain(ctx, db, command, readingHandler)
+	}
+	collection := db.Collection(command.Collection)
+	scanner := dsc.NewSQLScanner(command.statement, m.Config(), command.Columns)
+	switch command.Type {
+	case commandDistinct:
+		return m.readDistinct(ctx, collection, command, scanner, readingHandler)
+	case commandAggregate:
+		cursor, err := collection.Aggregate(ctx, command.Pipeline)
+		if err != nil {
+			return err
+		}
+		return m.scanAll(ctx, cursor, scanner, command.transform, readingHandler)
+	case commandFind:
+		findOptions := options.Find()
+		if command.Projection != nil {
+			findOptions.SetProjection(command.Projection)
+		}
+		if len(command.Sort) > 0 {
+			findOptions.SetSort(asSortDocument(command.Sort))
+		}
+		if command.Skip > 0 {
+			findOptions.SetSkip(int64(command.Skip))
+		}
+		if command.Limit > 0 {
+			findOptions.SetLimit(int64(command.Limit))
+		}
+		cursor, err := collection.Find(ctx, command.filterDocument(), findOptions)
+		if err != nil {
+			return err
+		}
+		return m.scanAll(ctx, cursor, scanner, command.transform, readingHandler)
+	}
+	return fmt.Errorf("unsupported query command type: %v", command.Type)
+}
+
+//explain runs explain command and passes query plan row to reading handler
+func (m *manager) explain(ctx context.Context, db *mongo.Database, command *Command, readingHandler func(scanner dsc.Scanner) (toContinue bool, err error)) error {
+	explainCommand, err := command.explainCommand()
 	if err != nil {
 		return err
 	}
-	m.updatePKIfNeeded(statement.Table, criteria, true)
-	collection := db.C(statement.Table)
+	var explain = make(map[string]interface{})
+	if err = db.RunCommand(ctx, explainCommand).Decode(&explain); err != nil {
+		return fmt.Errorf("failed to explain %v, %v", command.Collection, err)
+	}
+	scanner := dsc.NewSQLScanner(command.statement, m.Config(), explainColumns)
+	if scanner.Values, err = asExplainRecord(normalizeDocument(explain)); err != nil {
+		return err
+	}
+	_, err = readingHandler(scanner)
+	return err
+}
 
-	if len(criteria) == 0 {
-		criteria = nil
+//readDistinct reads distinct field values with server side distinct command
+func (m *manager) readDistinct(ctx context.Context, collection *mongo.Collection, command *Command, scanner *dsc.SQLScanner, readingHandler func(scanner dsc.Scanner) (toContinue bool, err error)) error {
+	values, err := collection.Distinct(ctx, command.Distinct, command.filterDocument())
+	if err != nil {
+		return err
 	}
-	var count = 0
-	iter := collection.Find(criteria).Iter()
-	scanner := dsc.NewSQLScanner(statement, m.Config(), nil)
-	for iter.Next(&scanner.Values) {
-		count++
+	for _, value := range values {
+		scanner.Values = map[string]interface{}{command.Columns[0]: normalizeValue(value)}
+		if command.transform != nil {
+			command.transform(scanner.Values)
+		}
 		toContinue, err := readingHandler(scanner)
 		if err != nil {
 			return err
@@ -190,15 +375,39 @@ func (m *manager) ReadAllOnWithHandlerOnConnection(connection dsc.Connection, SQ
 		if !toContinue {
 			break
 		}
-		scanner.Values = make(map[string]interface{})
 	}
 	return nil
 }
 
+//scanAll passes cursor documents to reading handler, documents are normalized and optional transform is applied to each document before scanning
+func (m *manager) scanAll(ctx context.Context, cursor *mongo.Cursor, scanner *dsc.SQLScanner, transform func(record map[string]interface{}), readingHandler func(scanner dsc.Scanner) (toContinue bool, err error)) error {
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var record = make(map[string]interface{})
+		if err := cursor.Decode(&record); err != nil {
+			return err
+		}
+		scanner.Values = normalizeDocument(record)
+		if transform != nil {
+			transform(scanner.Values)
+		}
+		toContinue, err := readingHandler(scanner)
+		if err != nil {
+			return err
+		}
+		if !toContinue {
+			break
+		}
+	}
+	return cursor.Err()
+}
+
 func newConfig(conf *dsc.Config) (*config, error) {
 	var keyColumnName = conf.GetString(pkColumnKey, mongoIDKey)
 	return &config{
-		Config:    conf,
-		keyColumn: keyColumnName,
+		Config:        conf,
+		keyColumn:     keyColumnName,
+		missingAsNull: conf.GetBoolean(missingAsNullKey, true),
+		flattenNested: conf.GetBoolean(flattenNestedKey, false),
 	}, nil
 }
@@ -1,10 +1,12 @@
 package mgc
 
 import (
-	"errors"
 	"github.com/viant/dsc"
+	"strings"
 )
 
+const mongoDBScheme = "mongodb://"
+
 type managerFactory struct{}
 
 func (f *managerFactory) Create(config *dsc.Config) (dsc.Manager, error) {
@@ -13,17 +15,24 @@ func (f *managerFactory) Create(config *dsc.Config) (dsc.Manager, error) {
 	var self dsc.Manager = manager
 	super := dsc.NewAbstractManager(config, connectionProvider, self)
 	manager.AbstractManager = super
-	var err error
-	dbname := config.Get(dbnameKey)
-	if dbname == "" {
-		return nil, errors.New("dbname was empty")
+	dbname, err := databaseName(config)
+	if err != nil {
+		return nil, err
 	}
 	manager.config, err = newConfig(config)
 	manager.config.dbName = dbname
 	return self, err
 }
 
+//CreateFromURL creates manager for mongodb:// connection URI, or for config loaded from URL
 func (f managerFactory) CreateFromURL(URL string) (dsc.Manager, error) {
+	if strings.HasPrefix(URL, mongoDBScheme) {
+		config, err := dsc.NewConfigWithParameters("mgc", "", "", map[string]interface{}{uriKey: URL})
+		if err != nil {
+			return nil, err
+		}
+		return f.Create(config)
+	}
 	config, err := dsc.NewConfigFromURL(URL)
 	if err != nil {
 		return nil, err
@@ -1,26 +1,47 @@
 package mgc_test
 
 import (
+	"context"
 	"fmt"
+	"github.com/adrianwit/mgc"
 	"github.com/stretchr/testify/assert"
 	"github.com/viant/assertly"
 	"github.com/viant/dsc"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"strings"
 	"testing"
 )
 
+var uniqueNameIndex = mongo.IndexModel{Keys: bson.D{{Key: "name", Value: 1}}, Options: options.Index().SetUnique(true)}
+
 type User struct {
 	Id   int    `column:"id"`
 	Name string `column:"name"`
 }
 
+type Account struct {
+	Id   string `column:"id"`
+	Name string `column:"name"`
+}
+
+type Profile struct {
+	Id   int    `column:"id"`
+	City string `column:"address.city"`
+	Zip  string `column:"address.zip"`
+}
+
 func TestManager(t *testing.T) {
 
 	//dsc.Logf = dsc.StdoutLogger
 
 	config, err := dsc.NewConfigWithParameters("mgc", "", "", map[string]interface{}{
-		"host":      "127.0.0.1",
-		"dbname":    "mydb",
-		"keyColumn": "id",
+		"host":             "127.0.0.1",
+		"dbname":           "mydb",
+		"keyColumn":        "id",
+		"accounts.keyType": "ObjectId",
 	})
 	if !assert.Nil(t, err) {
 		return
@@ -40,6 +61,8 @@ func TestManager(t *testing.T) {
 		assert.Nil(t, err)
 		affected, _ := sqlResult.RowsAffected()
 		assert.EqualValues(t, 1, affected)
+		lastInsertID, _ := sqlResult.LastInsertId()
+		assert.EqualValues(t, i, lastInsertID)
 	}
 
 	queryCases := []struct {
@@ -156,6 +179,31 @@ func TestManager(t *testing.T) {
 				},
 			},
 		},
+		{
+			Description: "Read records with order by and limit",
+			SQL:         "SELECT id, name FROM users ORDER BY id DESC LIMIT 2",
+			Expected: []*User{
+				{
+					Id:   2,
+					Name: "Name 2",
+				},
+				{
+					Id:   1,
+					Name: "Name 1",
+				},
+			},
+		},
+		{
+			Description: "Read records with criteria, limit and offset placeholders",
+			SQL:         "SELECT id, name FROM users WHERE id >= ? ORDER BY id LIMIT ? OFFSET ?",
+			Parameters:  []interface{}{0, 1, 1},
+			Expected: []*User{
+				{
+					Id:   1,
+					Name: "Name 1",
+				},
+			},
+		},
 	}
 
 	for _, useCase := range queryCases {
@@ -190,6 +238,340 @@ func TestManager(t *testing.T) {
 
 	}
 
+	{
+		var SQL = "SELECT name AS userName FROM users WHERE id = ?"
+		var records = make([]map[string]interface{}, 0)
+		err = manager.ReadAll(&records, SQL, []interface{}{1}, nil)
+		if !assert.Nil(t, err) {
+			return
+		}
+		if assert.EqualValues(t, 1, len(records)) {
+			assert.EqualValues(t, map[string]interface{}{"userName": "Name 1"}, records[0])
+		}
+	}
+
+	{
+		var SQL = "SELECT COUNT(*) AS cnt, MAX(id) AS maxId FROM users WHERE id > ?"
+		var records = make([]map[string]interface{}, 0)
+		err = manager.ReadAll(&records, SQL, []interface{}{0}, nil)
+		if !assert.Nil(t, err) {
+			return
+		}
+		if assert.EqualValues(t, 1, len(records)) {
+			assert.EqualValues(t, 2, records[0]["cnt"])
+			assert.EqualValues(t, 2, records[0]["maxId"])
+		}
+	}
+
+	{
+		var SQL = "SELECT COUNT(*) AS cnt, MAX(id) AS maxId FROM users WHERE id > ?"
+		var records = make([]map[string]interface{}, 0)
+		err = manager.ReadAll(&records, SQL, []interface{}{100}, nil)
+		if !assert.Nil(t, err) {
+			return
+		}
+		if assert.EqualValues(t, 1, len(records)) {
+			assert.EqualValues(t, 0, records[0]["cnt"])
+			assert.Nil(t, records[0]["maxId"])
+		}
+		var record = make(map[string]interface{})
+		success, err := manager.ReadSingle(&record, "SELECT COUNT(*) AS cnt FROM users WHERE id > ?", []interface{}{100}, nil)
+		if assert.Nil(t, err) && assert.True(t, success) {
+			assert.EqualValues(t, 0, record["cnt"])
+		}
+	}
+
+	{
+		var SQL = "SELECT name, COUNT(*) AS cnt FROM users GROUP BY name HAVING COUNT(*) >= ? ORDER BY name DESC LIMIT 2"
+		var records = make([]map[string]interface{}, 0)
+		err = manager.ReadAll(&records, SQL, []interface{}{1}, nil)
+		if !assert.Nil(t, err) {
+			return
+		}
+		if assert.EqualValues(t, 2, len(records)) {
+			assert.EqualValues(t, "Name 2", records[0]["name"])
+			assert.EqualValues(t, 1, records[0]["cnt"])
+		}
+	}
+
+	{
+		var SQL = "SELECT DISTINCT name FROM users WHERE id > ?"
+		var records = make([]map[string]interface{}, 0)
+		err = manager.ReadAll(&records, SQL, []interface{}{0}, nil)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.EqualValues(t, 2, len(records))
+	}
+
+	{
+		var SQL = "SELECT COUNT(DISTINCT name) AS names FROM users"
+		var records = make([]map[string]interface{}, 0)
+		err = manager.ReadAll(&records, SQL, nil, nil)
+		if !assert.Nil(t, err) {
+			return
+		}
+		if assert.EqualValues(t, 1, len(records)) {
+			assert.EqualValues(t, 3, records[0]["names"])
+		}
+	}
+
+	{ //Test join
+		dialect.DropTable(manager, "mydb", "orders")
+		_, err = manager.Execute("INSERT INTO orders(id, user_id, total) VALUES(?, ?, ?)", 1, 1, 10.5)
+		assert.Nil(t, err)
+
+		var SQL = "SELECT u.id, u.name, o.total FROM users u INNER JOIN orders o ON u.id = o.user_id WHERE o.total > ?"
+		var records = make([]map[string]interface{}, 0)
+		err = manager.ReadAll(&records, SQL, []interface{}{1}, nil)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.EqualValues(t, []map[string]interface{}{
+			{"id": 1, "name": "Name 1", "o_total": 10.5},
+		}, records)
+
+		SQL = "SELECT u.id, o.total AS total FROM users u LEFT JOIN orders o ON u.id = o.user_id ORDER BY u.id"
+		records = make([]map[string]interface{}, 0)
+		err = manager.ReadAll(&records, SQL, nil, nil)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.EqualValues(t, 3, len(records))
+	}
+
+	{ //Test nested fields
+		dialect.DropTable(manager, "mydb", "profiles")
+		_, err = manager.Execute("INSERT INTO profiles(id, address.city, address.zip) VALUES(?, ?, ?)", 1, "Warsaw", "00-001")
+		assert.Nil(t, err)
+		sqlResult, err := manager.Execute("UPDATE profiles SET address.city = ? WHERE id = ?", "Cracow", 1)
+		if assert.Nil(t, err) {
+			affected, _ := sqlResult.RowsAffected()
+			assert.EqualValues(t, 1, affected)
+		}
+		sqlResult, err = manager.Execute("UPDATE profiles SET address.city = ? WHERE id = ?", "Cracow", 2)
+		if assert.Nil(t, err) {
+			affected, _ := sqlResult.RowsAffected()
+			assert.EqualValues(t, 0, affected)
+		}
+
+		var records = make([]*Profile, 0)
+		err = manager.ReadAll(&records, "SELECT id, address.city, address.zip FROM profiles WHERE address.zip = ?", []interface{}{"00-001"}, nil)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assertly.AssertValues(t, []*Profile{
+			{
+				Id:   1,
+				City: "Cracow",
+				Zip:  "00-001",
+			},
+		}, records)
+
+		var documents = make([]map[string]interface{}, 0)
+		err = manager.ReadAll(&documents, "SELECT * FROM profiles", nil, nil)
+		if assert.Nil(t, err) && assert.EqualValues(t, 1, len(documents)) {
+			assert.NotNil(t, documents[0]["address"])
+			_, hasDottedKey := documents[0]["address.city"]
+			assert.False(t, hasDottedKey, "nested fields are not flattened by default")
+		}
+	}
+
+	{ //Test native filter and pipeline
+		var records = make([]*User, 0)
+		err = manager.ReadAll(&records, "SELECT id, name FROM users WHERE $json(?) ORDER BY id", []interface{}{`{"id": {"$in": [1, 2]}}`}, nil)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.EqualValues(t, 2, len(records))
+
+		var counts = make([]map[string]interface{}, 0)
+		err = manager.ReadAll(&counts, "SELECT * FROM users WHERE $json(?)", []interface{}{`[{"$match": {"id": {"$gt": 0}}}, {"$count": "total"}]`}, nil)
+		if !assert.Nil(t, err) {
+			return
+		}
+		if assert.EqualValues(t, 1, len(counts)) {
+			assert.EqualValues(t, 2, counts[0]["total"])
+		}
+	}
+
+	{ //Test multi-row insert
+		dialect.DropTable(manager, "mydb", "orders")
+		sqlResult, err := manager.Execute("INSERT INTO orders(id, user_id, total) VALUES(?, ?, ?), (?, ?, ?)", 10, 1, 3.5, 11, 2, 4.5)
+		if assert.Nil(t, err) {
+			affected, _ := sqlResult.RowsAffected()
+			assert.EqualValues(t, 2, affected)
+		}
+		dialect.DropTable(manager, "mydb", "orders")
+	}
+
+	{ //Test upsert
+		dialect.DropTable(manager, "mydb", "counters")
+		for i, expected := range []struct {
+			affected int64
+			hits     int
+		}{{1, 100}, {2, 101}} {
+			sqlResult, err := manager.Execute("INSERT INTO counters(id, name, hits) VALUES(?, ?, ?) ON DUPLICATE KEY UPDATE hits = hits + ?", 1, "home", 100, 1)
+			if assert.Nil(t, err, i) {
+				affected, _ := sqlResult.RowsAffected()
+				assert.EqualValues(t, expected.affected, affected, i)
+			}
+			var counters = make([]map[string]interface{}, 0)
+			err = manager.ReadAll(&counters, "SELECT id, name, hits FROM counters WHERE id = ?", []interface{}{1}, nil)
+			if assert.Nil(t, err, i) && assert.EqualValues(t, 1, len(counters), i) {
+				assert.EqualValues(t, expected.hits, counters[0]["hits"], i)
+				assert.EqualValues(t, "home", counters[0]["name"], i)
+			}
+		}
+		sqlResult, err := manager.Execute("UPSERT INTO counters(id, name, hits) VALUES(?, ?, ?)", 1, "home", 0)
+		if assert.Nil(t, err) {
+			affected, _ := sqlResult.RowsAffected()
+			assert.EqualValues(t, 2, affected)
+		}
+		dialect.DropTable(manager, "mydb", "counters")
+	}
+
+	{ //Test delete all and truncate
+		_, err = manager.Execute("INSERT INTO sessions(id, name) VALUES(?, ?), (?, ?)", 1, "s1", 2, "s2")
+		assert.Nil(t, err)
+		sqlResult, err := manager.Execute("DELETE FROM sessions")
+		if assert.Nil(t, err) {
+			affected, _ := sqlResult.RowsAffected()
+			assert.EqualValues(t, 2, affected)
+		}
+		_, err = manager.Execute("INSERT INTO sessions(id, name) VALUES(?, ?)", 3, "s3")
+		assert.Nil(t, err)
+		sqlResult, err = manager.Execute("TRUNCATE TABLE sessions")
+		if assert.Nil(t, err) {
+			affected, _ := sqlResult.RowsAffected()
+			assert.EqualValues(t, 1, affected)
+		}
+	}
+
+	{ //Test truncate preserving collection validator and indexes
+		dialect.DropTable(manager, "mydb", "tokens")
+		connection, err := manager.ConnectionProvider().Get()
+		if !assert.Nil(t, err) {
+			return
+		}
+		db := connection.Unwrap(mgc.DbPointer).(*mongo.Database)
+		err = db.RunCommand(context.Background(), bson.D{{Key: "create", Value: "tokens"}, {Key: "validator", Value: bson.M{"name": bson.M{"$type": "string"}}}}).Err()
+		if assert.Nil(t, err) {
+			_, err = db.Collection("tokens").Indexes().CreateOne(context.Background(), uniqueNameIndex)
+			assert.Nil(t, err)
+		}
+		connection.Close()
+		for i := 0; i < 2; i++ {
+			_, err = manager.Execute("INSERT INTO tokens(id, name) VALUES(?, ?)", 1, "t1")
+			assert.Nil(t, err, i)
+			_, err = manager.Execute("TRUNCATE TABLE tokens")
+			assert.Nil(t, err, i)
+		}
+		_, err = manager.Execute("INSERT INTO tokens(id, name) VALUES(?, ?)", 1, "t1")
+		assert.Nil(t, err)
+		_, err = manager.Execute("INSERT INTO tokens(id, name) VALUES(?, ?)", 2, "t1")
+		assert.NotNil(t, err, "unique index should be recreated")
+		_, err = manager.Execute("INSERT INTO tokens(id, name) VALUES(?, ?)", 3, 3)
+		assert.NotNil(t, err, "validator should be recreated")
+		dialect.DropTable(manager, "mydb", "tokens")
+
+		sqlResult, err := manager.Execute("TRUNCATE TABLE tokens")
+		if assert.Nil(t, err, "missing collection truncate") {
+			affected, _ := sqlResult.RowsAffected()
+			assert.EqualValues(t, 0, affected)
+		}
+		tables, err := dialect.GetTables(manager, "mydb")
+		if assert.Nil(t, err) {
+			assert.NotContains(t, tables, "tokens", "missing collection should not be created")
+		}
+	}
+
+	{ //Test generated _id
+		dialect.DropTable(manager, "mydb", "notes")
+		sqlResult, err := manager.Execute("INSERT INTO notes(name) VALUES(?), (?)", "n1", "n2")
+		if assert.Nil(t, err) {
+			affected, _ := sqlResult.RowsAffected()
+			assert.EqualValues(t, 2, affected)
+			result, ok := sqlResult.(*mgc.Result)
+			if assert.True(t, ok) {
+				id, ok := result.InsertedID().(primitive.ObjectID)
+				assert.True(t, ok)
+				assert.False(t, id.IsZero())
+			}
+		}
+		sqlResult, err = manager.Execute("INSERT INTO notes(id, name) VALUES(?, ?), (?, ?)", 1, "n3", 2, "n4")
+		if assert.Nil(t, err) {
+			lastInsertID, _ := sqlResult.LastInsertId()
+			assert.EqualValues(t, 2, lastInsertID)
+		}
+		dialect.DropTable(manager, "mydb", "notes")
+	}
+
+	{ //Test ObjectId key
+		var id = "5b2d6f6e1d41c8f1a8f3a1b2"
+		_, err = manager.Execute("DELETE FROM accounts")
+		assert.Nil(t, err)
+		_, err = manager.Execute("INSERT INTO accounts(id, name) VALUES(?, ?)", id, "acc1")
+		if !assert.Nil(t, err) {
+			return
+		}
+		var account = &Account{}
+		success, err := manager.ReadSingle(account, "SELECT id, name FROM accounts WHERE id = ?", []interface{}{id}, nil)
+		if assert.Nil(t, err) && assert.True(t, success) {
+			assert.EqualValues(t, id, account.Id)
+		}
+		var accounts = make([]*Account, 0)
+		err = manager.ReadAll(&accounts, "SELECT id, name FROM accounts WHERE $json(?)", []interface{}{`{"_id": {"$oid": "` + id + `"}}`}, nil)
+		if assert.Nil(t, err) {
+			assert.EqualValues(t, 1, len(accounts))
+		}
+		for _, SQL := range []string{
+			"SELECT id, name FROM accounts WHERE id = ? AND name = ?",
+			"SELECT id, name FROM accounts WHERE name = ? OR id IN (?)",
+		} {
+			accounts = make([]*Account, 0)
+			var parameters = []interface{}{id, "acc1"}
+			if strings.Contains(SQL, " OR ") {
+				parameters = []interface{}{"none", id}
+			}
+			err = manager.ReadAll(&accounts, SQL, parameters, nil)
+			if assert.Nil(t, err, SQL) && assert.EqualValues(t, 1, len(accounts), SQL) {
+				assert.EqualValues(t, id, accounts[0].Id, SQL)
+			}
+		}
+		for _, useCase := range []struct {
+			SQL     string
+			Columns []string
+		}{
+			{SQL: "SELECT DISTINCT _id FROM accounts", Columns: []string{"_id"}},
+			{SQL: "SELECT MAX(_id) AS max_id, COUNT(*) AS cnt FROM accounts", Columns: []string{"max_id"}},
+			{SQL: "SELECT a._id AS account_id, b._id AS other_id FROM accounts a JOIN accounts b ON a.name = b.name", Columns: []string{"account_id", "other_id"}},
+		} {
+			var records = make([]map[string]interface{}, 0)
+			err = manager.ReadAll(&records, useCase.SQL, nil, nil)
+			if !assert.Nil(t, err, useCase.SQL) || !assert.EqualValues(t, 1, len(records), useCase.SQL) {
+				continue
+			}
+			for _, column := range useCase.Columns {
+				assert.EqualValues(t, id, records[0][column], useCase.SQL)
+			}
+		}
+	}
+
+	{ //Test explain
+		var plans = make([]map[string]interface{}, 0)
+		err = manager.ReadAll(&plans, "EXPLAIN SELECT id, name FROM users WHERE id > ? ORDER BY id", []interface{}{0}, nil)
+		if !assert.Nil(t, err) {
+			return
+		}
+		if assert.EqualValues(t, 1, len(plans)) {
+			assert.NotNil(t, plans[0]["stage"])
+			assert.NotNil(t, plans[0]["plan"])
+			assert.NotNil(t, plans[0]["docsExamined"])
+			assert.NotNil(t, plans[0]["executionTimeMillis"])
+		}
+	}
+
 	{ //Test persist
 
 		var records = []*User{
@@ -215,3 +597,192 @@ func TestManager(t *testing.T) {
 	}
 
 }
+
+func TestManager_PersistBatches(t *testing.T) {
+	config, err := dsc.NewConfigWithParameters("mgc", "", "", map[string]interface{}{
+		"host":      "127.0.0.1",
+		"dbname":    "mydb",
+		"keyColumn": "id",
+		"batchSize": 2,
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+	factory := dsc.NewManagerFactory()
+	manager, err := factory.Create(config)
+	if err != nil {
+		fmt.Printf("make sure mongodb is runnig on localhost")
+		return
+	}
+	dialect := dsc.GetDatastoreDialect("mgc")
+	var resetBatches = func() bool {
+		dialect.DropTable(manager, "mydb", "batches")
+		connection, err := manager.ConnectionProvider().Get()
+		if !assert.Nil(t, err) {
+			return false
+		}
+		defer connection.Close()
+		db := connection.Unwrap(mgc.DbPointer).(*mongo.Database)
+		if _, err = db.Collection("batches").Indexes().CreateOne(context.Background(), uniqueNameIndex); !assert.Nil(t, err) {
+			return false
+		}
+		_, err = manager.Execute("INSERT INTO batches(id, name) VALUES(?, ?)", 1, "Name 1")
+		return assert.Nil(t, err)
+	}
+	var records = []*User{
+		{Id: 1, Name: "Name 11"},
+		{Id: 2, Name: "Name 2"},
+		{Id: 3, Name: "Name 3"},
+		{Id: 4, Name: "Name 2"},
+	}
+
+	{ //Test per batch counts with duplicate
+		if !resetBatches() {
+			return
+		}
+		results, err := mgc.PersistBatches(manager, &records, "batches", nil)
+		batchError, ok := err.(*mgc.BatchError)
+		if assert.True(t, ok, fmt.Sprintf("expected *mgc.BatchError, but had %v", err)) {
+			assert.EqualValues(t, []int{3}, batchError.Duplicates())
+		}
+		assert.EqualValues(t, []*mgc.BatchResult{{Inserted: 1, Updated: 1}, {Inserted: 1}}, results)
+	}
+
+	{ //Test persist on connection with duplicate
+		if !resetBatches() {
+			return
+		}
+		connection, err := manager.ConnectionProvider().Get()
+		if !assert.Nil(t, err) {
+			return
+		}
+		defer connection.Close()
+		inserted, updated, err := manager.PersistAllOnConnection(connection, &records, "batches", nil)
+		batchError, ok := err.(*mgc.BatchError)
+		if assert.True(t, ok, fmt.Sprintf("expected *mgc.BatchError, but had %v", err)) {
+			assert.EqualValues(t, []int{3}, batchError.Positions())
+			assert.EqualValues(t, []int{3}, batchError.Duplicates())
+		}
+		assert.EqualValues(t, 2, inserted)
+		assert.EqualValues(t, 1, updated)
+	}
+
+	{ //Test record by record fallback
+		config, err := dsc.NewConfigWithParameters("mgc", "", "", map[string]interface{}{
+			"host":      "127.0.0.1",
+			"dbname":    "mydb",
+			"keyColumn": "id",
+			"batchSize": 1,
+		})
+		if !assert.Nil(t, err) {
+			return
+		}
+		manager, err := factory.Create(config)
+		if !assert.Nil(t, err) || !resetBatches() {
+			return
+		}
+		var records = records[:3]
+		inserted, updated, err := manager.PersistAll(&records, "batches", nil)
+		assert.Nil(t, err)
+		assert.EqualValues(t, 2, inserted)
+		assert.EqualValues(t, 1, updated)
+	}
+}
+
+func TestManager_Transaction(t *testing.T) {
+	config, err := dsc.NewConfigWithParameters("mgc", "", "", map[string]interface{}{
+		"host":         "127.0.0.1",
+		"dbname":       "mydb",
+		"keyColumn":    "id",
+		"transactions": true,
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+	factory := dsc.NewManagerFactory()
+	manager, err := factory.Create(config)
+	if err != nil {
+		fmt.Printf("make sure mongodb is runnig on localhost")
+		return
+	}
+	connection, err := manager.ConnectionProvider().Get()
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer connection.Close()
+	var isMaster = bson.M{}
+	db := connection.Unwrap(mgc.DbPointer).(*mongo.Database)
+	if !assert.Nil(t, db.RunCommand(context.Background(), bson.D{{Key: "isMaster", Value: 1}}).Decode(&isMaster)) {
+		return
+	}
+	if isMaster["setName"] == nil && isMaster["msg"] != "isdbgrid" {
+		t.Skip("transactions require mongodb replica set or sharded cluster")
+	}
+	_, err = manager.Execute("DELETE FROM transfers")
+	assert.Nil(t, err)
+
+	var countTransfers = func(expected int) {
+		var counts = make([]map[string]interface{}, 0)
+		err := manager.ReadAll(&counts, "SELECT COUNT(*) AS cnt FROM transfers", nil, nil)
+		if assert.Nil(t, err) && assert.EqualValues(t, 1, len(counts)) {
+			assert.EqualValues(t, expected, counts[0]["cnt"])
+		}
+	}
+
+	if !assert.Nil(t, connection.Begin()) {
+		return
+	}
+	_, err = manager.ExecuteOnConnection(connection, "INSERT INTO transfers(id, amount) VALUES(?, ?)", []interface{}{1, 10})
+	assert.Nil(t, err)
+	assert.Nil(t, connection.Rollback())
+	countTransfers(0)
+
+	assert.Nil(t, connection.Begin())
+	_, err = manager.ExecuteOnConnection(connection, "INSERT INTO transfers(id, amount) VALUES(?, ?), (?, ?)", []interface{}{1, 10, 2, 20})
+	assert.Nil(t, err)
+	assert.Nil(t, connection.Commit())
+	countTransfers(2)
+
+	var runs = 0
+	err = mgc.WithTransaction(manager, func(connection dsc.Connection) error {
+		runs++
+		if _, err := manager.ExecuteOnConnection(connection, "UPDATE transfers SET amount = amount - ? WHERE id = ?", []interface{}{5, 1}); err != nil {
+			return err
+		}
+		_, err := manager.ExecuteOnConnection(connection, "INSERT INTO transfers(id, amount) VALUES(?, ?)", []interface{}{1, 5})
+		return err
+	})
+	assert.NotNil(t, err, "duplicate key should roll the transaction back")
+	assert.EqualValues(t, 1, runs, "duplicate key is not transient error")
+	var transfers = make([]map[string]interface{}, 0)
+	err = manager.ReadAll(&transfers, "SELECT id, amount FROM transfers WHERE id = ?", []interface{}{1}, nil)
+	if assert.Nil(t, err) && assert.EqualValues(t, 1, len(transfers)) {
+		assert.EqualValues(t, 10, transfers[0]["amount"])
+	}
+
+	err = mgc.WithTransaction(manager, func(connection dsc.Connection) error {
+		_, err := manager.ExecuteOnConnection(connection, "INSERT INTO transfers(id, amount) VALUES(?, ?)", []interface{}{3, 30})
+		return err
+	})
+	assert.Nil(t, err)
+	countTransfers(3)
+
+	runs = 0
+	err = mgc.WithTransaction(manager, func(connection dsc.Connection) error {
+		runs++
+		err := manager.ReadAllOnWithHandlerOnConnection(connection, "SELECT id, amount FROM transfers", nil, func(scanner dsc.Scanner) (bool, error) {
+			return true, nil
+		})
+		if err != nil {
+			return err
+		}
+		if runs == 1 {
+			return fmt.Errorf("failed to read transfers, %w", mongo.CommandError{Code: 251, Labels: []string{"TransientTransactionError"}})
+		}
+		_, err = manager.ExecuteOnConnection(connection, "INSERT INTO transfers(id, amount) VALUES(?, ?)", []interface{}{4, 40})
+		return err
+	})
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2, runs, "wrapped transient handler error should run the handler again")
+	countTransfers(4)
+}
@@ -0,0 +1,132 @@
+package mgc
+
+import (
+	"fmt"
+	"github.com/viant/toolbox"
+	"go.mongodb.org/mongo-driver/bson"
+	"regexp"
+	"strings"
+)
+
+const nativePlaceholder = `\$json\s*\(\s*\?\s*\)`
+
+var nativeExpr = regexp.MustCompile(`(?i)` + nativePlaceholder)
+var nativeSelectExpr = regexp.MustCompile(`(?is)^\s*SELECT\s+(.+?)\s+FROM\s+([\w.]+)\s+WHERE\s+` + nativePlaceholder + `\s*$`)
+var nativeInsertExpr = regexp.MustCompile(`(?is)^\s*INSERT\s+INTO\s+([\w.]+)\s+VALUES\s*` + nativePlaceholder + `\s*$`)
+var nativeUpdateExpr = regexp.MustCompile(`(?is)^\s*UPDATE\s+([\w.]+)\s+SET\s+` + nativePlaceholder + `(\s+WHERE\s+` + nativePlaceholder + `)?\s*$`)
+var nativeDeleteExpr = regexp.MustCompile(`(?is)^\s*DELETE\s+FROM\s+([\w.]+)\s+WHERE\s+` + nativePlaceholder + `\s*$`)
+
+//nativeStatement represents statement with native mongo documents supplied with $json(?) placeholders
+type nativeStatement struct {
+	Type      string //SELECT, INSERT, UPDATE or DELETE
+	Table     string
+	Columns   string        //select list
+	Filter    interface{}   //find, update or remove filter
+	Pipeline  []interface{} //aggregation pipeline, when SELECT $json(?) parameter is an array
+	Update    interface{}   //update document
+	Documents []interface{} //insert documents
+}
+
+//isNativeStatement returns true if SQL uses $json(?) placeholder
+func isNativeStatement(SQL string) bool {
+	return nativeExpr.MatchString(SQL)
+}
+
+//asNativeDocument converts $json(?) parameter into mongo document, JSON text is decoded as MongoDB extended JSON with documents as ordered bson.D
+func asNativeDocument(parameter interface{}) (interface{}, error) {
+	var data []byte
+	switch actual := parameter.(type) {
+	case string:
+		data = []byte(actual)
+	case []byte:
+		data = actual
+	case bson.D, bson.M, map[string]interface{}, []interface{}:
+		return actual, nil
+	case bson.A:
+		return asNativeValue(actual), nil
+	default:
+		if toolbox.IsMap(parameter) || toolbox.IsSlice(parameter) || toolbox.IsStruct(parameter) {
+			return parameter, nil
+		}
+		return nil, fmt.Errorf("unsupported $json parameter type: %T", parameter)
+	}
+	var wrapper = struct {
+		Value interface{} `bson:"value"`
+	}{}
+	if err := bson.UnmarshalExtJSON([]byte(`{"value": `+string(data)+`}`), false, &wrapper); err != nil {
+		return nil, fmt.Errorf("failed to decode $json parameter %s, %v", data, err)
+	}
+	return asNativeValue(wrapper.Value), nil
+}
+
+//asNativeValue converts decoded bson.A arrays into slices recursively, documents are kept as ordered bson.D so that i.e. $sort stage keeps its keys order
+func asNativeValue(value interface{}) interface{} {
+	switch actual := value.(type) {
+	case bson.A:
+		var result = make([]interface{}, len(actual))
+		for i, item := range actual {
+			result[i] = asNativeValue(item)
+		}
+		return result
+	case bson.D:
+		var result = bson.D{}
+		for _, element := range actual {
+			result = append(result, bson.E{Key: element.Key, Value: asNativeValue(element.Value)})
+		}
+		return result
+	}
+	return value
+}
+
+//asNativeDocuments returns documents for array parameter or single document parameter
+func asNativeDocuments(document interface{}) []interface{} {
+	if isArray(document) {
+		return toolbox.AsSlice(document)
+	}
+	return []interface{}{document}
+}
+
+//parseNativeStatement parses SQL with $json(?) placeholders, each placeholder takes the corresponding parameter
+func parseNativeStatement(SQL string, parameters []interface{}) (*nativeStatement, error) {
+	var expected = len(nativeExpr.FindAllStringIndex(SQL, -1))
+	if expected != len(parameters) {
+		return nil, fmt.Errorf("expected %v $json parameters, but had %v", expected, len(parameters))
+	}
+	var documents = make([]interface{}, 0)
+	for _, parameter := range parameters {
+		document, err := asNativeDocument(parameter)
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, document)
+	}
+	var result = &nativeStatement{}
+	if matched := nativeSelectExpr.FindStringSubmatch(SQL); len(matched) > 0 {
+		result.Type, result.Columns, result.Table = "SELECT", strings.TrimSpace(matched[1]), matched[2]
+		if isArray(documents[0]) {
+			result.Pipeline = toolbox.AsSlice(documents[0])
+			return result, nil
+		}
+		result.Filter = documents[0]
+		return result, nil
+	}
+	if matched := nativeInsertExpr.FindStringSubmatch(SQL); len(matched) > 0 {
+		result.Type, result.Table = "INSERT", matched[1]
+		result.Documents = asNativeDocuments(documents[0])
+		return result, nil
+	}
+	if matched := nativeUpdateExpr.FindStringSubmatch(SQL); len(matched) > 0 {
+		result.Type, result.Table = "UPDATE", matched[1]
+		result.Update = documents[0]
+		if len(documents) > 1 {
+			result.Filter = documents[1]
+		}
+		return result, nil
+	}
+	if matched := nativeDeleteExpr.FindStringSubmatch(SQL); len(matched) > 0 {
+		result.Type, result.Table = "DELETE", matched[1]
+		result.Filter = documents[0]
+		return result, nil
+	}
+	return nil, fmt.Errorf("unsupported $json statement: %v", SQL)
+}
@@ -0,0 +1,126 @@
+package mgc
+
+import (
+	"github.com/viant/dsc"
+	"strings"
+)
+
+//logicalTerms splits criteria by top level AND and OR operators, AND of BETWEEN operand is not treated as logical operator
+func logicalTerms(criteria string) ([]string, []string) {
+	var terms = make([]string, 0)
+	var operators = make([]string, 0)
+	var start = 0
+	var between = false
+	scanUnquoted(criteria, func(i, depth int) bool {
+		if depth != 0 || i < start {
+			return true
+		}
+		if end := matchKeyword(criteria, i, "BETWEEN"); end != -1 {
+			between = true
+			return true
+		}
+		for _, operator := range []string{"AND", "OR"} {
+			end := matchKeyword(criteria, i, operator)
+			if end == -1 {
+				continue
+			}
+			if operator == "AND" && between {
+				between = false
+				return true
+			}
+			terms = append(terms, strings.TrimSpace(criteria[start:i]))
+			operators = append(operators, operator)
+			start = end
+			return true
+		}
+		return true
+	})
+	terms = append(terms, strings.TrimSpace(criteria[start:]))
+	return terms, operators
+}
+
+//groupNestedTerm returns term with parenthesized criteria group, optionally negated, processed by groupLogicalOperators
+func groupNestedTerm(term string) string {
+	var prefix = ""
+	if end := matchKeyword(term, 0, "NOT"); end != -1 {
+		prefix = term[:end] + " "
+		term = strings.TrimSpace(term[end:])
+	}
+	if !strings.HasPrefix(term, "(") {
+		return prefix + term
+	}
+	var closing = -1
+	scanUnquoted(term, func(i, depth int) bool {
+		if depth == 0 && term[i] == ')' {
+			closing = i
+			return false
+		}
+		return true
+	})
+	if closing != len(term)-1 {
+		return prefix + term
+	}
+	return prefix + "(" + groupLogicalOperators(term[1:closing]) + ")"
+}
+
+//groupLogicalOperators returns criteria with AND terms parenthesized when mixed with OR, so that AND takes precedence over OR, parenthesized groups are processed recursively
+func groupLogicalOperators(criteria string) string {
+	terms, operators := logicalTerms(criteria)
+	for i, term := range terms {
+		terms[i] = groupNestedTerm(term)
+	}
+	var hasAnd, hasOr = false, false
+	for _, operator := range operators {
+		hasAnd = hasAnd || operator == "AND"
+		hasOr = hasOr || operator == "OR"
+	}
+	if !hasAnd || !hasOr {
+		var result = terms[0]
+		for i, operator := range operators {
+			result += " " + operator + " " + terms[i+1]
+		}
+		return result
+	}
+	var groups = make([]string, 0)
+	var group = []string{terms[0]}
+	var appendGroup = func() {
+		if len(group) == 1 {
+			groups = append(groups, group[0])
+			return
+		}
+		groups = append(groups, "("+strings.Join(group, " AND ")+")")
+	}
+	for i, operator := range operators {
+		if operator == "AND" {
+			group = append(group, terms[i+1])
+			continue
+		}
+		appendGroup()
+		group = []string{terms[i+1]}
+	}
+	appendGroup()
+	return strings.Join(groups, " OR ")
+}
+
+//groupWhereCriteria returns SQL with WHERE clause criteria processed by groupLogicalOperators
+func groupWhereCriteria(SQL string) string {
+	var index = indexKeyword(SQL, "WHERE")
+	if index == -1 {
+		return SQL
+	}
+	var criteria = strings.TrimSpace(SQL[index+len("WHERE"):])
+	if criteria == "" {
+		return SQL
+	}
+	return SQL[:index] + "WHERE " + groupLogicalOperators(criteria)
+}
+
+//parseQuery parses query, AND criteria take precedence over OR criteria
+func parseQuery(SQL string) (*dsc.QueryStatement, error) {
+	return dsc.NewQueryParser().Parse(groupWhereCriteria(SQL))
+}
+
+//parseDML parses INSERT, UPDATE or DELETE statement, AND criteria take precedence over OR criteria
+func parseDML(SQL string) (*dsc.DmlStatement, error) {
+	return dsc.NewDmlParser().Parse(groupWhereCriteria(SQL))
+}
@@ -0,0 +1,304 @@
+package mgc
+
+import (
+	"fmt"
+	"github.com/viant/dsc"
+	"github.com/viant/toolbox"
+	"go.mongodb.org/mongo-driver/bson"
+	"sort"
+	"strings"
+)
+
+//queryOptions represents query ordering and pagination options
+type queryOptions struct {
+	OrderBy []string //sort fields, descending fields are prefixed with '-'
+	Limit   int
+	Offset  int
+}
+
+//scanUnquoted calls handler with index and parenthesis depth of each character outside quoted literals, parenthesis are reported with their enclosing depth,
+//scanning stops when handler returns false
+func scanUnquoted(text string, handler func(index, depth int) bool) {
+	var depth = 0
+	var quote = byte(0)
+	for i := 0; i < len(text); i++ {
+		var aChar = text[i]
+		if quote != 0 {
+			if aChar == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch aChar {
+		case '\'', '"', '`':
+			quote = aChar
+			continue
+		case ')':
+			depth--
+		}
+		if !handler(i, depth) {
+			return
+		}
+		if aChar == '(' {
+			depth++
+		}
+	}
+}
+
+//countPlaceholders returns number of '?' placeholders outside quotes
+func countPlaceholders(SQL string) int {
+	var result = 0
+	scanUnquoted(SQL, func(i, depth int) bool {
+		if SQL[i] == '?' {
+			result++
+		}
+		return true
+	})
+	return result
+}
+
+//findKeyword returns start and end index of the last case insensitive keyword outside quotes and parenthesis, or -1 if SQL has no keyword
+func findKeyword(SQL, keyword string) (int, int) {
+	return findKeywordWithOperand(SQL, keyword, nil)
+}
+
+//findKeywordWithOperand returns start and end index of the last case insensitive keyword outside quotes and parenthesis accepted by operand predicate, or -1
+func findKeywordWithOperand(SQL, keyword string, isOperand func(operand string) bool) (int, int) {
+	var start, end = -1, -1
+	scanUnquoted(SQL, func(i, depth int) bool {
+		if depth != 0 {
+			return true
+		}
+		if matched := matchKeyword(SQL, i, keyword); matched != -1 && (isOperand == nil || isOperand(SQL[matched:])) {
+			start, end = i, matched
+		}
+		return true
+	})
+	return start, end
+}
+
+//isPaginationOperand returns true if text starts with numeric or '?' LIMIT or OFFSET operand, so that columns named limit or offset are not taken for clauses
+func isPaginationOperand(text string) bool {
+	text = strings.TrimLeft(text, " \t\r\n")
+	return text != "" && (text[0] == '?' || (text[0] >= '0' && text[0] <= '9'))
+}
+
+//indexKeyword returns last index of keyword outside quotes and parenthesis, or -1
+func indexKeyword(SQL, keyword string) int {
+	index, _ := findKeyword(SQL, keyword)
+	return index
+}
+
+//matchKeyword returns end index of case insensitive keyword starting at index, words of multi-word keyword can be separated by any whitespace, or -1 if keyword does not start at index
+func matchKeyword(text string, index int, keyword string) int {
+	if index > 0 && isWordChar(text[index-1]) {
+		return -1
+	}
+	var end = index
+	for i, word := range strings.Fields(keyword) {
+		if i > 0 {
+			var start = end
+			for end < len(text) && isSpace(text[end]) {
+				end++
+			}
+			if end == start {
+				return -1
+			}
+		}
+		if end+len(word) > len(text) || !strings.EqualFold(text[end:end+len(word)], word) {
+			return -1
+		}
+		end += len(word)
+	}
+	if end < len(text) && isWordChar(text[end]) {
+		return -1
+	}
+	return end
+}
+
+func isSpace(aChar byte) bool {
+	return aChar == ' ' || aChar == '\t' || aChar == '\n' || aChar == '\r'
+}
+
+func isWordChar(aChar byte) bool {
+	return aChar == '_' || aChar == '.' || aChar == '$' || (aChar >= '0' && aChar <= '9') || (aChar >= 'a' && aChar <= 'z') || (aChar >= 'A' && aChar <= 'Z')
+}
+
+//extractQueryOptions removes ORDER BY, LIMIT and OFFSET clauses from SQL, placeholders used by these clauses are taken from the end of parameters
+func extractQueryOptions(SQL string, parameters []interface{}) (string, []interface{}, *queryOptions, error) {
+	var result = &queryOptions{}
+	var tailIndex = len(SQL)
+	var clauses = map[string]int{}
+	var clauseEnds = map[string]int{}
+	var keywords = make([]string, 0)
+	for _, keyword := range []string{"ORDER BY", "LIMIT", "OFFSET"} {
+		var isOperand func(operand string) bool
+		if keyword != "ORDER BY" {
+			isOperand = isPaginationOperand
+		}
+		if index, end := findKeywordWithOperand(SQL, keyword, isOperand); index != -1 {
+			clauses[keyword] = index
+			clauseEnds[keyword] = end
+			keywords = append(keywords, keyword)
+			if index < tailIndex {
+				tailIndex = index
+			}
+		}
+	}
+	sort.Slice(keywords, func(i, j int) bool {
+		return clauses[keywords[i]] < clauses[keywords[j]]
+	})
+	if len(clauses) == 0 {
+		return SQL, parameters, result, nil
+	}
+	var tail = SQL[tailIndex:]
+	SQL = strings.TrimSpace(SQL[:tailIndex])
+	var placeholders = countPlaceholders(tail)
+	if placeholders > len(parameters) {
+		return "", nil, nil, fmt.Errorf("expected %v parameters for '%v', but had %v", placeholders, tail, len(parameters))
+	}
+	var tailParameters = parameters[len(parameters)-placeholders:]
+	parameters = parameters[:len(parameters)-placeholders]
+
+	var clause = func(keyword string) string {
+		var start = clauseEnds[keyword] - tailIndex
+		var end = len(tail)
+		for _, index := range clauses {
+			if index-tailIndex >= start && index-tailIndex < end {
+				end = index - tailIndex
+			}
+		}
+		return strings.TrimSpace(tail[start:end])
+	}
+	var tailParameterIndex = 0
+	var asInt = func(keyword, value string) (int, error) {
+		if value == "?" {
+			if tailParameterIndex >= len(tailParameters) {
+				return 0, fmt.Errorf("missing %v parameter", keyword)
+			}
+			value = toolbox.AsString(tailParameters[tailParameterIndex])
+			tailParameterIndex++
+		}
+		result, err := toolbox.ToInt(value)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %v value: %v", keyword, value)
+		}
+		return result, nil
+	}
+	var err error
+	for _, keyword := range keywords {
+		switch keyword {
+		case "ORDER BY":
+			if result.OrderBy, err = asSortFields(clause(keyword)); err != nil {
+				return "", nil, nil, err
+			}
+		case "LIMIT":
+			var limit = clause(keyword)
+			if index := strings.Index(limit, ","); index != -1 { //MySQL LIMIT offset, count form
+				if result.Offset, err = asInt("OFFSET", strings.TrimSpace(limit[:index])); err != nil {
+					return "", nil, nil, err
+				}
+				limit = strings.TrimSpace(limit[index+1:])
+			}
+			if result.Limit, err = asInt(keyword, limit); err != nil {
+				return "", nil, nil, err
+			}
+		case "OFFSET":
+			if result.Offset, err = asInt(keyword, clause(keyword)); err != nil {
+				return "", nil, nil, err
+			}
+		}
+	}
+	return SQL, parameters, result, nil
+}
+
+//asSortFields converts ORDER BY clause into sort fields
+func asSortFields(orderBy string) ([]string, error) {
+	var result = make([]string, 0)
+	for _, item := range strings.Split(orderBy, ",") {
+		var fragments = strings.Fields(item)
+		if len(fragments) == 0 || len(fragments) > 2 {
+			return nil, fmt.Errorf("invalid ORDER BY item: '%v'", item)
+		}
+		var field = fragments[0]
+		if len(fragments) == 2 {
+			switch strings.ToUpper(fragments[1]) {
+			case "DESC":
+				field = "-" + field
+			case "ASC":
+			default:
+				return nil, fmt.Errorf("invalid ORDER BY direction: '%v'", fragments[1])
+			}
+		}
+		result = append(result, field)
+	}
+	return result, nil
+}
+
+//asProjection returns mongo projection for selected columns, nil if all fields are selected, _id is suppressed unless selected
+func asProjection(statement *dsc.QueryStatement) map[string]interface{} {
+	if statement.AllField || len(statement.Columns) == 0 {
+		return nil
+	}
+	var result = make(map[string]interface{})
+	for _, column := range statement.Columns {
+		var name = strings.TrimSpace(column.Name)
+		if name == "*" || name == "" || strings.Contains(name, "(") {
+			return nil
+		}
+		result[projectionPath(name)] = 1
+	}
+	if _, has := result[mongoIDKey]; !has {
+		result[mongoIDKey] = 0
+	}
+	return result
+}
+
+//mapSortFields returns sort fields with names resolved by mapping function, descending fields keep '-' prefix
+func mapSortFields(fields []string, mapping func(name string) string) []string {
+	if len(fields) == 0 {
+		return nil
+	}
+	var result = make([]string, 0)
+	for _, field := range fields {
+		var direction = ""
+		if strings.HasPrefix(field, "-") {
+			direction = "-"
+			field = field[1:]
+		}
+		if mapping != nil {
+			field = mapping(field)
+		}
+		result = append(result, direction+field)
+	}
+	return result
+}
+
+//asSortDocument returns ordered $sort document for sort fields, descending fields are prefixed with '-'
+func asSortDocument(fields []string) bson.D {
+	var result = bson.D{}
+	for _, field := range fields {
+		var direction = 1
+		if strings.HasPrefix(field, "-") {
+			direction = -1
+			field = field[1:]
+		}
+		result = append(result, bson.E{Key: field, Value: direction})
+	}
+	return result
+}
+
+//asPipelineOptions returns $sort, $skip and $limit stages for query options, sort field names are resolved by optional mapping function
+func asPipelineOptions(options *queryOptions, mapping func(name string) string) []interface{} {
+	var result = make([]interface{}, 0)
+	if len(options.OrderBy) > 0 {
+		result = append(result, bson.M{"$sort": asSortDocument(mapSortFields(options.OrderBy, mapping))})
+	}
+	if options.Offset > 0 {
+		result = append(result, bson.M{"$skip": options.Offset})
+	}
+	if options.Limit > 0 {
+		result = append(result, bson.M{"$limit": options.Limit})
+	}
+	return result
+}
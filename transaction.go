@@ -0,0 +1,200 @@
+package mgc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/viant/dsc"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	transactionsKey           = "transactions"
+	transactionRetriesKey     = "transactionRetries"
+	defaultTransactionRetries = 3
+)
+
+//transaction error labels, see MongoDB transactions error handling
+const (
+	transientTransactionLabel = "TransientTransactionError"
+	unknownCommitResultLabel  = "UnknownTransactionCommitResult"
+)
+
+//hasErrorLabel returns true if error, or error it wraps, has the supplied MongoDB error label
+func hasErrorLabel(err error, label string) bool {
+	var labeled mongo.LabeledError
+	if errors.As(err, &labeled) {
+		return labeled.HasErrorLabel(label)
+	}
+	return false
+}
+
+//transaction represents driver session with multi-document transaction
+type transaction struct {
+	session   mongo.Session
+	retries   int
+	transient bool //transaction failed with transient error, it can be run again
+}
+
+func newTransaction(client *mongo.Client, retries int) (*transaction, error) {
+	session, err := client.StartSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start session, %v", err)
+	}
+	if err = session.StartTransaction(); err != nil {
+		session.EndSession(context.Background())
+		return nil, err
+	}
+	return &transaction{session: session, retries: retries}, nil
+}
+
+//context returns session context, operations run with the context are part of the transaction
+func (t *transaction) context() context.Context {
+	return mongo.NewSessionContext(context.Background(), t.session)
+}
+
+//commit commits the transaction, commit is retried when its result is unknown, transient error marks the transaction to be run again by the caller
+func (t *transaction) commit() error {
+	err := t.session.CommitTransaction(context.Background())
+	for attempt := 0; attempt < t.retries && hasErrorLabel(err, unknownCommitResultLabel); attempt++ {
+		err = t.session.CommitTransaction(context.Background())
+	}
+	t.transient = hasErrorLabel(err, transientTransactionLabel)
+	return err
+}
+
+//abort aborts the transaction
+func (t *transaction) abort() error {
+	return t.session.AbortTransaction(context.Background())
+}
+
+//end ends the session
+func (t *transaction) end() {
+	t.session.EndSession(context.Background())
+}
+
+//asTransaction returns active connection transaction or nil
+func asTransaction(dscConnection dsc.Connection) *transaction {
+	if mgoConnection, ok := dscConnection.(*connection); ok {
+		return mgoConnection.transaction
+	}
+	return nil
+}
+
+//Begin starts multi-document transaction if transactions are enabled, otherwise it does nothing
+func (c *connection) Begin() error {
+	config := c.Config()
+	if !config.GetBoolean(transactionsKey, false) {
+		return nil
+	}
+	return c.begin()
+}
+
+//begin starts multi-document transaction
+func (c *connection) begin() error {
+	if c.transaction != nil {
+		return fmt.Errorf("transaction has been already started")
+	}
+	var err error
+	c.transaction, err = newTransaction(c.client, c.Config().GetInt(transactionRetriesKey, defaultTransactionRetries))
+	return err
+}
+
+//WithTransaction runs handler within multi-document transaction on a new manager connection and commits it, handler error rolls the transaction back,
+//after transient transaction error, returned by the handler or recorded by transaction writes and commit, the handler is run again in the new transaction
+//up to transactionRetries times, regardless of transactions config
+func WithTransaction(dscManager dsc.Manager, handler func(connection dsc.Connection) error) error {
+	dscConnection, err := dscManager.ConnectionProvider().Get()
+	if err != nil {
+		return err
+	}
+	defer dscConnection.Close()
+	mgcConnection, ok := dscConnection.(*connection)
+	if !ok {
+		return fmt.Errorf("unsupported connection: %T", dscConnection)
+	}
+	for attempt := 0; ; attempt++ {
+		if err = mgcConnection.begin(); err != nil {
+			return err
+		}
+		var transaction = mgcConnection.transaction
+		if err = handler(mgcConnection); err == nil {
+			err = mgcConnection.Commit()
+		} else {
+			_ = mgcConnection.Rollback()
+		}
+		var transient = transaction.transient || hasErrorLabel(err, transientTransactionLabel)
+		if err == nil || !transient || attempt >= transaction.retries {
+			return err
+		}
+	}
+}
+
+//Commit commits active transaction
+func (c *connection) Commit() error {
+	if c.transaction == nil {
+		return nil
+	}
+	var transaction = c.transaction
+	c.transaction = nil
+	defer transaction.end()
+	return transaction.commit()
+}
+
+//Rollback aborts active transaction
+func (c *connection) Rollback() error {
+	if c.transaction == nil {
+		return nil
+	}
+	var transaction = c.transaction
+	c.transaction = nil
+	defer transaction.end()
+	return transaction.abort()
+}
+
+//asContext returns active connection transaction context, or background context
+func asContext(connection dsc.Connection) context.Context {
+	if transaction := asTransaction(connection); transaction != nil {
+		return transaction.context()
+	}
+	return context.Background()
+}
+
+//executeInTransaction runs command within the transaction, it returns affected documents count and _id of the last inserted document,
+//transient error marks the transaction to be run again by the caller
+func (m *manager) executeInTransaction(transaction *transaction, db *mongo.Database, command *Command) (int, interface{}, error) {
+	if command.Type == commandTruncate {
+		return 0, nil, fmt.Errorf("%v is not supported within transaction", command.Type)
+	}
+	var ctx = transaction.context()
+	var affected int
+	var insertedID interface{}
+	var err error
+	if command.Type == commandUpsert && len(command.Documents) > 0 {
+		affected, insertedID, err = m.insertOrUpdateInTransaction(ctx, db.Collection(command.Collection), command)
+	} else {
+		affected, insertedID, err = m.execute(ctx, db, command)
+	}
+	transaction.transient = transaction.transient || hasErrorLabel(err, transientTransactionLabel)
+	return affected, insertedID, err
+}
+
+//insertOrUpdateInTransaction updates document matched by upsert command filter, or inserts upsert command document if none is matched,
+//duplicate key error would abort the transaction, so existing document is looked up first
+func (m *manager) insertOrUpdateInTransaction(ctx context.Context, collection *mongo.Collection, command *Command) (int, interface{}, error) {
+	err := collection.FindOne(ctx, command.Filter).Err()
+	if err == nil {
+		if _, err = collection.UpdateOne(ctx, command.Filter, command.Update); err != nil {
+			return 0, nil, err
+		}
+		return upsertUpdated, nil, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return 0, nil, err
+	}
+	result, err := collection.InsertOne(ctx, command.Documents[0])
+	if err != nil {
+		return 0, nil, err
+	}
+	return upsertInserted, result.InsertedID, nil
+}
@@ -0,0 +1,622 @@
+package mgc
+
+import (
+	"fmt"
+	"github.com/viant/dsc"
+	"github.com/viant/toolbox"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+const (
+	commandFind      = "find"
+	commandDistinct  = "distinct"
+	commandAggregate = "aggregate"
+	commandInsert    = "insert"
+	commandUpsert    = "upsert"
+	commandUpdate    = "update"
+	commandRemove    = "remove"
+	commandTruncate  = "truncate"
+)
+
+var truncateExpr = regexp.MustCompile(`(?is)^\s*TRUNCATE\s+(?:TABLE\s+)?([\w.]+)\s*;?\s*$`)
+
+//Command represents MongoDB command for SQL statement
+type Command struct {
+	Type       string                 `json:"type"` //find, distinct, aggregate, insert, upsert, update, remove or truncate
+	Collection string                 `json:"collection"`
+	Filter     interface{}            `json:"filter,omitempty"`
+	Projection map[string]interface{} `json:"projection,omitempty"`
+	Sort       []string               `json:"sort,omitempty"` //sort fields, descending fields are prefixed with '-'
+	Skip       int                    `json:"skip,omitempty"`
+	Limit      int                    `json:"limit,omitempty"`
+	Distinct   string                 `json:"distinct,omitempty"` //distinct command field
+	Pipeline   []interface{}          `json:"pipeline,omitempty"`
+	Update     interface{}            `json:"update,omitempty"`
+	Merge      bool                   `json:"merge,omitempty"` //update fields are merged into the matched document which is then replaced
+	Documents  []interface{}          `json:"documents,omitempty"`
+	Columns    []string               `json:"columns,omitempty"` //output columns
+	Explain    bool                   `json:"explain,omitempty"` //query plan is returned instead of query results
+	statement  *dsc.QueryStatement
+	transform  func(record map[string]interface{})
+}
+
+//MarshalJSON returns command as relaxed MongoDB extended JSON, so that ordered documents, ObjectId, dates or regular expressions are encoded as in mongo shell, empty fields are omitted
+func (c *Command) MarshalJSON() ([]byte, error) {
+	var document = bson.D{{Key: "type", Value: c.Type}, {Key: "collection", Value: c.Collection}}
+	for _, element := range []bson.E{
+		{Key: "filter", Value: c.Filter},
+		{Key: "projection", Value: c.Projection},
+		{Key: "sort", Value: c.Sort},
+		{Key: "skip", Value: c.Skip},
+		{Key: "limit", Value: c.Limit},
+		{Key: "distinct", Value: c.Distinct},
+		{Key: "pipeline", Value: c.Pipeline},
+		{Key: "update", Value: c.Update},
+		{Key: "merge", Value: c.Merge},
+		{Key: "documents", Value: c.Documents},
+		{Key: "columns", Value: c.Columns},
+		{Key: "explain", Value: c.Explain},
+	} {
+		if !isEmptyValue(element.Value) {
+			document = append(document, element)
+		}
+	}
+	return bson.MarshalExtJSON(document, false, false)
+}
+
+//isEmptyValue returns true for nil, zero value, or empty string, slice or map
+func isEmptyValue(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	aValue := reflect.ValueOf(value)
+	switch aValue.Kind() {
+	case reflect.Map, reflect.Slice, reflect.String:
+		return aValue.Len() == 0
+	}
+	return aValue.IsZero()
+}
+
+//filterDocument returns command filter, or empty document matching all documents if filter is not set
+func (c *Command) filterDocument() interface{} {
+	if c.Filter == nil {
+		return bson.M{}
+	}
+	return c.Filter
+}
+
+//Translate returns MongoDB command for SQL statement without running it, default key column and NULL handling are used
+func Translate(SQL string, parameters []interface{}) (*Command, error) {
+	conf, err := newConfig(&dsc.Config{Parameters: make(map[string]interface{})})
+	if err != nil {
+		return nil, err
+	}
+	return conf.translate(SQL, parameters)
+}
+
+func isQuery(SQL string) bool {
+	var fragments = strings.Fields(SQL)
+	if len(fragments) == 0 {
+		return false
+	}
+	var keyword = strings.ToUpper(fragments[0])
+	return keyword == "SELECT" || keyword == "EXPLAIN"
+}
+
+func (c *config) getKeyColumn(table string) string {
+	if keyColumn := c.GetString(table+"."+pkColumnKey, ""); keyColumn != "" {
+		return keyColumn
+	}
+	return c.keyColumn
+}
+
+//isObjectIDKey returns true if table key is configured as ObjectId
+func (c *config) isObjectIDKey(table string) bool {
+	keyType := c.GetString(table+"."+keyTypeKey, c.GetString(keyTypeKey, ""))
+	return strings.EqualFold(keyType, objectIDKeyType)
+}
+
+func (c *config) updatePKIfNeeded(table string, record map[string]interface{}, replace bool) {
+	if _, has := record[mongoIDKey]; !has {
+		keyColumn := c.getKeyColumn(table)
+		if id, has := record[keyColumn]; has {
+			record[mongoIDKey] = id
+			if replace {
+				delete(record, keyColumn)
+			}
+		}
+	}
+	if id, has := record[mongoIDKey]; has && c.isObjectIDKey(table) {
+		record[mongoIDKey] = asObjectIDs(id)
+	}
+}
+
+//updateCriteriaPKIfNeeded maps key column to _id within the whole criteria tree, including $and, $or and $nor nested criteria
+func (c *config) updateCriteriaPKIfNeeded(table string, criteria map[string]interface{}) {
+	for _, operator := range []string{"$and", "$or", "$nor"} {
+		if nested, ok := criteria[operator].([]map[string]interface{}); ok {
+			for _, item := range nested {
+				c.updateCriteriaPKIfNeeded(table, item)
+			}
+		}
+	}
+	c.updatePKIfNeeded(table, criteria, true)
+}
+
+//isKeyField returns true if field refers to table key column or _id
+func (c *config) isKeyField(table, field string) bool {
+	return field == mongoIDKey || field == c.getKeyColumn(table)
+}
+
+//keyTransform returns transform converting ObjectId key back to hex string before applying the supplied transform, key column is set if missing
+func (c *config) keyTransform(table string, transform func(record map[string]interface{})) func(record map[string]interface{}) {
+	if !c.isObjectIDKey(table) {
+		return transform
+	}
+	keyColumn := c.getKeyColumn(table)
+	return func(record map[string]interface{}) {
+		if id, ok := record[keyColumn].(primitive.ObjectID); ok {
+			record[keyColumn] = id.Hex()
+		}
+		if id, ok := record[mongoIDKey].(primitive.ObjectID); ok {
+			record[mongoIDKey] = id.Hex()
+			if _, has := record[keyColumn]; !has {
+				record[keyColumn] = id.Hex()
+			}
+		}
+		if transform != nil {
+			transform(record)
+		}
+	}
+}
+
+//hexTransform returns transform applying the supplied transform, then converting ObjectId values of the supplied columns to hex string
+func hexTransform(columns []string, transform func(record map[string]interface{})) func(record map[string]interface{}) {
+	if len(columns) == 0 {
+		return transform
+	}
+	return func(record map[string]interface{}) {
+		if transform != nil {
+			transform(record)
+		}
+		for _, column := range columns {
+			if id, ok := record[column].(primitive.ObjectID); ok {
+				record[column] = id.Hex()
+			}
+		}
+	}
+}
+
+//aggregateKeyColumns returns output columns selecting ObjectId key directly or with MIN and MAX
+func (c *config) aggregateKeyColumns(table string, aggregate *aggregateQuery) []string {
+	var result = make([]string, 0)
+	if !c.isObjectIDKey(table) {
+		return result
+	}
+	for _, item := range aggregate.Items {
+		switch item.Function {
+		case "":
+			if c.isKeyField(table, item.Expression) {
+				result = append(result, item.Name())
+			}
+		case "MIN", "MAX":
+			if c.isKeyField(table, item.Argument) {
+				result = append(result, item.Name())
+			}
+		}
+	}
+	return result
+}
+
+//joinKeyColumns returns output columns selecting ObjectId key of the queried or joined collections, with all fields selected joined collections keys are returned
+func (c *config) joinKeyColumns(joins *joinQuery) []string {
+	var result = make([]string, 0)
+	var columns = joins.Columns()
+	if columns == nil {
+		for _, join := range joins.Joins {
+			if c.isObjectIDKey(join.Table) {
+				result = append(result, join.Alias+"_"+mongoIDKey, join.Alias+"_"+c.getKeyColumn(join.Table))
+			}
+		}
+		return result
+	}
+	for i, item := range joins.Items {
+		var table, field = joins.Table, joins.path(item.Expression)
+		if index := strings.Index(field, "."); index != -1 {
+			for _, join := range joins.Joins {
+				if join.Alias == field[:index] {
+					table, field = join.Table, field[index+1:]
+				}
+			}
+		}
+		if c.isObjectIDKey(table) && c.isKeyField(table, field) {
+			result = append(result, columns[i])
+		}
+	}
+	return result
+}
+
+//asObjectIDs converts 24 hex strings into ObjectId, criteria operator documents and arrays are converted recursively
+func asObjectIDs(value interface{}) interface{} {
+	switch actual := value.(type) {
+	case string:
+		if id, err := primitive.ObjectIDFromHex(actual); err == nil {
+			return id
+		}
+	case []interface{}, bson.A:
+		items, _ := asArray(actual)
+		var result = make([]interface{}, len(items))
+		for i, item := range items {
+			result[i] = asObjectIDs(item)
+		}
+		return result
+	case map[string]interface{}:
+		var result = make(map[string]interface{})
+		for k, v := range actual {
+			result[k] = asObjectIDs(v)
+		}
+		return result
+	case bson.M:
+		var result = bson.M{}
+		for k, v := range actual {
+			result[k] = asObjectIDs(v)
+		}
+		return result
+	case bson.D:
+		var result = bson.D{}
+		for _, element := range actual {
+			result = append(result, bson.E{Key: element.Key, Value: asObjectIDs(element.Value)})
+		}
+		return result
+	}
+	return value
+}
+
+func (c *config) criteria(statement *dsc.BaseStatement, parameters toolbox.Iterator) (map[string]interface{}, error) {
+	criteriaValues, err := statement.CriteriaValues(parameters)
+	if err != nil {
+		return nil, err
+	}
+	return newCriteriaContext(criteriaValues, c.missingAsNull).asMongoCriteria(statement.SQLCriteria)
+}
+
+//filter returns statement criteria with key column mapped to _id
+func (c *config) filter(statement *dsc.BaseStatement, parameters []interface{}) (map[string]interface{}, error) {
+	criteria, err := c.criteria(statement, toolbox.NewSliceIterator(parameters))
+	if err != nil {
+		return nil, err
+	}
+	c.updateCriteriaPKIfNeeded(statement.Table, criteria)
+	return criteria, nil
+}
+
+//asFilter returns command filter, empty criteria are returned as nil to match all documents
+func asFilter(criteria map[string]interface{}) interface{} {
+	if len(criteria) == 0 {
+		return nil
+	}
+	return criteria
+}
+
+func (c *config) translate(SQL string, parameters []interface{}) (*Command, error) {
+	if isQuery(SQL) {
+		return c.translateQuery(SQL, parameters)
+	}
+	return c.translateDML(SQL, parameters)
+}
+
+//translateQuery translates SELECT or EXPLAIN SELECT statement
+func (c *config) translateQuery(SQL string, parameters []interface{}) (*Command, error) {
+	if SQL, isExplain := extractExplain(SQL); isExplain {
+		command, err := c.translateQuery(SQL, parameters)
+		if err != nil {
+			return nil, err
+		}
+		command.Explain = true
+		return command, nil
+	}
+	SQL, parameters, options, err := extractQueryOptions(SQL, parameters)
+	if err != nil {
+		return nil, err
+	}
+	if isNativeStatement(SQL) {
+		return c.translateNativeQuery(SQL, parameters, options)
+	}
+	SQL, joins, err := extractJoinQuery(SQL)
+	if err != nil {
+		return nil, err
+	}
+	SQL, aggregate, err := extractAggregateQuery(SQL)
+	if err != nil {
+		return nil, err
+	}
+	statement, err := parseQuery(SQL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse statement %v, %v", SQL, err)
+	}
+	if joins != nil {
+		return c.translateJoin(statement, joins, parameters, options)
+	}
+	if aggregate != nil {
+		return c.translateAggregate(statement, aggregate, parameters, options)
+	}
+	filter, err := c.filter(statement.BaseStatement, parameters)
+	if err != nil {
+		return nil, err
+	}
+	return &Command{
+		Type:       commandFind,
+		Collection: statement.Table,
+		Filter:     asFilter(filter),
+		Projection: asProjection(statement),
+		Sort:       sortFields(statement, options),
+		Skip:       options.Offset,
+		Limit:      options.Limit,
+		statement:  statement,
+		transform: c.keyTransform(statement.Table, func(record map[string]interface{}) {
+			if statement.AllField && c.flattenNested {
+				flattenDocument(record)
+			}
+			enrichRecordIfNeeded(statement, record)
+		}),
+	}, nil
+}
+
+//sortFields returns sort fields, fields referencing column aliases are resolved to column names
+func sortFields(statement *dsc.QueryStatement, options *queryOptions) []string {
+	var aliases = make(map[string]string)
+	for _, column := range statement.Columns {
+		if column.Alias != "" {
+			aliases[column.Alias] = column.Name
+		}
+	}
+	return mapSortFields(options.OrderBy, func(name string) string {
+		if column, ok := aliases[name]; ok {
+			return column
+		}
+		return name
+	})
+}
+
+//translateNativeQuery translates SELECT statement with $json(?) filter or pipeline
+func (c *config) translateNativeQuery(SQL string, parameters []interface{}, options *queryOptions) (*Command, error) {
+	native, err := parseNativeStatement(SQL, parameters)
+	if err != nil {
+		return nil, err
+	}
+	if native.Type != "SELECT" {
+		return nil, fmt.Errorf("expected SELECT $json statement, but had %v", native.Type)
+	}
+	statement, err := dsc.NewQueryParser().Parse("SELECT " + native.Columns + " FROM " + native.Table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse statement columns %v, %v", native.Columns, err)
+	}
+	var result = &Command{
+		Collection: native.Table,
+		statement:  statement,
+		transform: c.keyTransform(native.Table, func(record map[string]interface{}) {
+			enrichRecordIfNeeded(statement, record)
+		}),
+	}
+	if native.Pipeline != nil {
+		result.Type = commandAggregate
+		result.Pipeline = append(native.Pipeline, asPipelineOptions(options, nil)...)
+		return result, nil
+	}
+	result.Type = commandFind
+	result.Filter = native.Filter
+	result.Projection = asProjection(statement)
+	result.Sort = sortFields(statement, options)
+	result.Skip = options.Offset
+	result.Limit = options.Limit
+	return result, nil
+}
+
+//translateJoin translates query with joined collections into $lookup pipeline
+func (c *config) translateJoin(statement *dsc.QueryStatement, joins *joinQuery, parameters []interface{}, options *queryOptions) (*Command, error) {
+	criteria, err := c.criteria(statement.BaseStatement, toolbox.NewSliceIterator(parameters))
+	if err != nil {
+		return nil, err
+	}
+	criteria = renameCriteriaFields(criteria, joins.path)
+	c.updateCriteriaPKIfNeeded(statement.Table, criteria)
+	var result = &Command{
+		Type:       commandAggregate,
+		Collection: statement.Table,
+		Pipeline:   joins.Pipeline(criteria, options),
+		Columns:    joins.Columns(),
+		statement:  statement,
+	}
+	var flatten func(record map[string]interface{})
+	if result.Columns == nil {
+		flatten = joins.Flatten
+	}
+	result.transform = c.keyTransform(statement.Table, hexTransform(c.joinKeyColumns(joins), flatten))
+	return result, nil
+}
+
+//translateAggregate translates aggregate query into aggregation pipeline or distinct command, HAVING placeholders follow WHERE placeholders
+func (c *config) translateAggregate(statement *dsc.QueryStatement, aggregate *aggregateQuery, parameters []interface{}, options *queryOptions) (*Command, error) {
+	var havingSQL = aggregate.HavingSQL()
+	var havingParameterCount = countPlaceholders(havingSQL)
+	if havingParameterCount > len(parameters) {
+		return nil, fmt.Errorf("expected %v HAVING parameters, but had %v", havingParameterCount, len(parameters))
+	}
+	var whereParameters = parameters[:len(parameters)-havingParameterCount]
+	criteria, err := c.filter(statement.BaseStatement, whereParameters)
+	if err != nil {
+		return nil, err
+	}
+	var having map[string]interface{}
+	if havingSQL != "" {
+		havingStatement, err := parseQuery("SELECT * FROM " + statement.Table + " WHERE " + havingSQL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse HAVING %v, %v", aggregate.Having, err)
+		}
+		if having, err = c.criteria(havingStatement.BaseStatement, toolbox.NewSliceIterator(parameters[len(whereParameters):])); err != nil {
+			return nil, err
+		}
+	}
+	var result = &Command{
+		Collection: statement.Table,
+		Columns:    aggregate.Columns(),
+		statement:  statement,
+		transform:  c.keyTransform(statement.Table, hexTransform(c.aggregateKeyColumns(statement.Table, aggregate), nil)),
+	}
+	if field := aggregate.DistinctField(options); field != "" {
+		result.Type = commandDistinct
+		result.Distinct = field
+		result.Filter = asFilter(criteria)
+		return result, nil
+	}
+	result.Type = commandAggregate
+	result.Pipeline = aggregate.Pipeline(criteria, having, options)
+	return result, nil
+}
+
+//translateDML translates INSERT, UPDATE or DELETE statement
+func (c *config) translateDML(SQL string, parameters []interface{}) (*Command, error) {
+	if isNativeStatement(SQL) {
+		return c.translateNativeDML(SQL, parameters)
+	}
+	if matched := truncateExpr.FindStringSubmatch(SQL); len(matched) > 0 {
+		return &Command{Type: commandTruncate, Collection: matched[1]}, nil
+	}
+	if isUpsert(SQL) {
+		return c.translateUpsert(SQL, parameters)
+	}
+	if prefix, tuples := extractInsertTuples(SQL); tuples != nil {
+		return c.translateMultiInsert(prefix, tuples, parameters)
+	}
+	expressions, parameters, err := extractUpdateExpressions(SQL, parameters)
+	if err != nil {
+		return nil, err
+	}
+	if expressions != nil {
+		if SQL = expressions.PlainSQL(); SQL == "" {
+			return c.translateExpressionUpdate(expressions, parameters)
+		}
+	}
+	statement, err := parseDML(SQL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %v due to %v", SQL, err)
+	}
+	var result = &Command{Collection: statement.Table}
+	switch statement.Type {
+	case "INSERT":
+		record, err := statement.ColumnValueMap(toolbox.NewSliceIterator(parameters))
+		if err != nil {
+			return nil, err
+		}
+		c.updatePKIfNeeded(statement.Table, record, false)
+		result.Type = commandInsert
+		result.Documents = []interface{}{expandPaths(record)}
+	case "UPDATE":
+		iterator := toolbox.NewSliceIterator(parameters)
+		record, err := statement.ColumnValueMap(iterator)
+		if err != nil {
+			return nil, err
+		}
+		criteria, err := c.criteria(statement.BaseStatement, iterator)
+		if err != nil {
+			return nil, err
+		}
+		c.updateCriteriaPKIfNeeded(statement.Table, criteria)
+		return c.updateCommand(statement.Table, record, criteria, expressions)
+	case "DELETE":
+		result.Type = commandRemove
+		if len(statement.Criteria) == 0 {
+			return result, nil
+		}
+		filter, err := c.filter(statement.BaseStatement, parameters)
+		if err != nil {
+			return nil, err
+		}
+		result.Filter = asFilter(filter)
+	default:
+		return nil, fmt.Errorf("unsupported statement type: %v", statement.Type)
+	}
+	return result, nil
+}
+
+//translateMultiInsert translates multi-row INSERT into single insert command, placeholders are consumed across tuples
+func (c *config) translateMultiInsert(prefix string, tuples []string, parameters []interface{}) (*Command, error) {
+	var result *Command
+	var paramIndex = 0
+	for _, tuple := range tuples {
+		var count = countPlaceholders(tuple)
+		if paramIndex+count > len(parameters) {
+			return nil, fmt.Errorf("array out of bound, %v %v", paramIndex+count, len(parameters))
+		}
+		command, err := c.translateDML(prefix+tuple, parameters[paramIndex:paramIndex+count])
+		if err != nil {
+			return nil, err
+		}
+		paramIndex += count
+		if result == nil {
+			result = command
+			continue
+		}
+		result.Documents = append(result.Documents, command.Documents...)
+	}
+	return result, nil
+}
+
+//translateExpressionUpdate translates UPDATE statement with arithmetic assignments only
+func (c *config) translateExpressionUpdate(expressions *updateExpressions, parameters []interface{}) (*Command, error) {
+	statement, err := parseQuery(strings.TrimSpace("SELECT * FROM " + expressions.Table + " " + expressions.Where))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse UPDATE %v criteria, %v", expressions.Table, err)
+	}
+	criteria, err := c.filter(statement.BaseStatement, parameters)
+	if err != nil {
+		return nil, err
+	}
+	return c.updateCommand(expressions.Table, nil, criteria, expressions)
+}
+
+//isReplaceUpdate returns true if UPDATE replaces matched document instead of using update operators
+func (c *config) isReplaceUpdate(table string) bool {
+	return c.GetBoolean(table+"."+replaceOnUpdateKey, c.GetBoolean(replaceOnUpdateKey, false))
+}
+
+//updateCommand returns update command with $set, $inc and $mul operators, or merge command if replace on update is configured
+func (c *config) updateCommand(table string, record, criteria map[string]interface{}, expressions *updateExpressions) (*Command, error) {
+	var result = &Command{Type: commandUpdate, Collection: table, Filter: asFilter(criteria)}
+	if c.isReplaceUpdate(table) {
+		if expressions != nil {
+			return nil, fmt.Errorf("arithmetic assignments are not supported with %v", replaceOnUpdateKey)
+		}
+		result.Update = record
+		result.Merge = true
+		return result, nil
+	}
+	result.Update = asUpdateDocument(record, expressions)
+	return result, nil
+}
+
+//translateNativeDML translates INSERT, UPDATE or DELETE statement with $json(?) documents
+func (c *config) translateNativeDML(SQL string, parameters []interface{}) (*Command, error) {
+	native, err := parseNativeStatement(SQL, parameters)
+	if err != nil {
+		return nil, err
+	}
+	var result = &Command{Collection: native.Table, Filter: native.Filter}
+	switch native.Type {
+	case "INSERT":
+		result.Type = commandInsert
+		result.Documents = native.Documents
+	case "UPDATE":
+		result.Type = commandUpdate
+		result.Update = native.Update
+	case "DELETE":
+		result.Type = commandRemove
+	default:
+		return nil, fmt.Errorf("expected INSERT, UPDATE or DELETE $json statement, but had %v", native.Type)
+	}
+	return result, nil
+}
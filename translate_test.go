@@ -0,0 +1,308 @@
+package mgc_test
+
+import (
+	"encoding/json"
+	"github.com/adrianwit/mgc"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"testing"
+)
+
+func TestTranslate(t *testing.T) {
+	var useCases = []struct {
+		Description string
+		SQL         string
+		Parameters  []interface{}
+		Expected    *mgc.Command
+	}{
+		{
+			Description: "find with criteria and options",
+			SQL:         "SELECT id, name FROM users WHERE id > ? ORDER BY name DESC LIMIT 10 OFFSET 5",
+			Parameters:  []interface{}{1},
+			Expected: &mgc.Command{
+				Type:       "find",
+				Collection: "users",
+				Filter:     map[string]interface{}{"id": map[string]interface{}{"$gt": 1}},
+				Projection: map[string]interface{}{"id": 1, "name": 1, "_id": 0},
+				Sort:       []string{"-name"},
+				Skip:       5,
+				Limit:      10,
+			},
+		},
+		{
+			Description: "find with column named offset",
+			SQL:         "SELECT id, offset FROM users WHERE offset > ? ORDER BY offset LIMIT ?",
+			Parameters:  []interface{}{10, 5},
+			Expected: &mgc.Command{
+				Type:       "find",
+				Collection: "users",
+				Filter:     map[string]interface{}{"offset": map[string]interface{}{"$gt": 10}},
+				Projection: map[string]interface{}{"id": 1, "offset": 1, "_id": 0},
+				Sort:       []string{"offset"},
+				Limit:      5,
+			},
+		},
+		{
+			Description: "find with ORDER BY keywords within quoted literal",
+			SQL:         "SELECT * FROM users WHERE note = 'sort order  by date' order\n by id",
+			Expected: &mgc.Command{
+				Type:       "find",
+				Collection: "users",
+				Filter:     map[string]interface{}{"note": map[string]interface{}{"$eq": "sort order  by date"}},
+				Sort:       []string{"id"},
+			},
+		},
+		{
+			Description: "find all",
+			SQL:         "SELECT * FROM users",
+			Expected: &mgc.Command{
+				Type:       "find",
+				Collection: "users",
+			},
+		},
+		{
+			Description: "explain",
+			SQL:         "EXPLAIN SELECT * FROM users WHERE name = ?",
+			Parameters:  []interface{}{"abc"},
+			Expected: &mgc.Command{
+				Type:       "find",
+				Collection: "users",
+				Filter:     map[string]interface{}{"name": map[string]interface{}{"$eq": "abc"}},
+				Explain:    true,
+			},
+		},
+		{
+			Description: "distinct",
+			SQL:         "SELECT DISTINCT country FROM users",
+			Expected: &mgc.Command{
+				Type:       "distinct",
+				Collection: "users",
+				Distinct:   "country",
+				Columns:    []string{"country"},
+			},
+		},
+		{
+			Description: "insert",
+			SQL:         "INSERT INTO users(id, name) VALUES(?, ?)",
+			Parameters:  []interface{}{1, "abc"},
+			Expected: &mgc.Command{
+				Type:       "insert",
+				Collection: "users",
+				Documents:  []interface{}{map[string]interface{}{"id": 1, "name": "abc"}},
+			},
+		},
+		{
+			Description: "multi-row insert",
+			SQL:         "INSERT INTO users(id, name) VALUES(?, ?), (?, 'xyz')",
+			Parameters:  []interface{}{1, "abc", 2},
+			Expected: &mgc.Command{
+				Type:       "insert",
+				Collection: "users",
+				Documents: []interface{}{
+					map[string]interface{}{"id": 1, "name": "abc"},
+					map[string]interface{}{"id": 2, "name": "xyz"},
+				},
+			},
+		},
+		{
+			Description: "multi-row insert with placeholder within quoted literal",
+			SQL:         "INSERT INTO users(id, name) VALUES(?, 'who?'), (?, ?)",
+			Parameters:  []interface{}{1, 2, "abc"},
+			Expected: &mgc.Command{
+				Type:       "insert",
+				Collection: "users",
+				Documents: []interface{}{
+					map[string]interface{}{"id": 1, "name": "who?"},
+					map[string]interface{}{"id": 2, "name": "abc"},
+				},
+			},
+		},
+		{
+			Description: "upsert",
+			SQL:         "UPSERT INTO users(_id, name) VALUES(?, ?)",
+			Parameters:  []interface{}{2, "xyz"},
+			Expected: &mgc.Command{
+				Type:       "upsert",
+				Collection: "users",
+				Filter:     bson.M{"_id": 2},
+				Update:     bson.M{"$set": bson.M{"name": "xyz"}},
+			},
+		},
+		{
+			Description: "upsert with key column only",
+			SQL:         "UPSERT INTO users(_id) VALUES(?)",
+			Parameters:  []interface{}{2},
+			Expected: &mgc.Command{
+				Type:       "upsert",
+				Collection: "users",
+				Filter:     bson.M{"_id": 2},
+				Update:     bson.M{"$setOnInsert": bson.M{"_id": 2}},
+			},
+		},
+		{
+			Description: "insert on duplicate key update",
+			SQL:         "INSERT INTO users(_id, name, hits, city) VALUES(?, ?, ?, ?) ON DUPLICATE KEY UPDATE hits = hits + ?, name = VALUES(name)",
+			Parameters:  []interface{}{1, "abc", 1, "Warsaw", 1},
+			Expected: &mgc.Command{
+				Type:       "upsert",
+				Collection: "users",
+				Filter:     bson.M{"_id": 1},
+				Update: bson.M{
+					"$inc": map[string]interface{}{"hits": 1},
+					"$set": map[string]interface{}{"name": "abc"},
+				},
+				Documents: []interface{}{
+					map[string]interface{}{"_id": 1, "name": "abc", "hits": 1, "city": "Warsaw"},
+				},
+			},
+		},
+		{
+			Description: "update with $set and $inc",
+			SQL:         "UPDATE users SET hits = hits + ?, name = ? WHERE id = ?",
+			Parameters:  []interface{}{1, "abc", 2},
+			Expected: &mgc.Command{
+				Type:       "update",
+				Collection: "users",
+				Filter:     map[string]interface{}{"id": map[string]interface{}{"$eq": 2}},
+				Update: bson.M{
+					"$set": map[string]interface{}{"name": "abc"},
+					"$inc": map[string]interface{}{"hits": 1},
+				},
+			},
+		},
+		{
+			Description: "update with $inc and $mul only",
+			SQL:         "UPDATE users SET hits = hits - ?, score = score * 2 WHERE id = ?",
+			Parameters:  []interface{}{3, 1},
+			Expected: &mgc.Command{
+				Type:       "update",
+				Collection: "users",
+				Filter:     map[string]interface{}{"id": map[string]interface{}{"$eq": 1}},
+				Update: bson.M{
+					"$inc": map[string]interface{}{"hits": -3},
+					"$mul": map[string]interface{}{"score": 2},
+				},
+			},
+		},
+		{
+			Description: "delete",
+			SQL:         "DELETE FROM users WHERE id = ?",
+			Parameters:  []interface{}{1},
+			Expected: &mgc.Command{
+				Type:       "remove",
+				Collection: "users",
+				Filter:     map[string]interface{}{"id": map[string]interface{}{"$eq": 1}},
+			},
+		},
+		{
+			Description: "delete all",
+			SQL:         "DELETE FROM users",
+			Expected: &mgc.Command{
+				Type:       "remove",
+				Collection: "users",
+			},
+		},
+		{
+			Description: "truncate",
+			SQL:         "TRUNCATE TABLE users",
+			Expected: &mgc.Command{
+				Type:       "truncate",
+				Collection: "users",
+			},
+		},
+		{
+			Description: "native update",
+			SQL:         "UPDATE users SET $json(?) WHERE $json(?)",
+			Parameters:  []interface{}{bson.M{"$inc": bson.M{"visits": 1}}, bson.M{"id": 1}},
+			Expected: &mgc.Command{
+				Type:       "update",
+				Collection: "users",
+				Filter:     bson.M{"id": 1},
+				Update:     bson.M{"$inc": bson.M{"visits": 1}},
+			},
+		},
+		{
+			Description: "native JSON filter keeps fields order",
+			SQL:         "SELECT * FROM users WHERE $json(?)",
+			Parameters:  []interface{}{`{"b": "x", "a": "y"}`},
+			Expected: &mgc.Command{
+				Type:       "find",
+				Collection: "users",
+				Filter:     bson.D{{Key: "b", Value: "x"}, {Key: "a", Value: "y"}},
+			},
+		},
+		{
+			Description: "native JSON pipeline keeps fields order",
+			SQL:         "SELECT * FROM users WHERE $json(?) ORDER BY name LIMIT 5",
+			Parameters:  []interface{}{`[{"$sort": {"b": 1, "a": -1}}]`},
+			Expected: &mgc.Command{
+				Type:       "aggregate",
+				Collection: "users",
+				Pipeline: []interface{}{
+					bson.D{{Key: "$sort", Value: bson.D{{Key: "b", Value: int32(1)}, {Key: "a", Value: int32(-1)}}}},
+					bson.M{"$sort": bson.D{{Key: "name", Value: 1}}},
+					bson.M{"$limit": 5},
+				},
+			},
+		},
+	}
+	for _, useCase := range useCases {
+		command, err := mgc.Translate(useCase.SQL, useCase.Parameters)
+		if !assert.Nil(t, err, useCase.Description) {
+			continue
+		}
+		assert.EqualValues(t, useCase.Expected.Type, command.Type, useCase.Description)
+		assert.EqualValues(t, useCase.Expected.Collection, command.Collection, useCase.Description)
+		assert.EqualValues(t, useCase.Expected.Filter, command.Filter, useCase.Description)
+		assert.EqualValues(t, useCase.Expected.Projection, command.Projection, useCase.Description)
+		assert.EqualValues(t, useCase.Expected.Sort, command.Sort, useCase.Description)
+		assert.EqualValues(t, useCase.Expected.Skip, command.Skip, useCase.Description)
+		assert.EqualValues(t, useCase.Expected.Limit, command.Limit, useCase.Description)
+		assert.EqualValues(t, useCase.Expected.Distinct, command.Distinct, useCase.Description)
+		assert.EqualValues(t, useCase.Expected.Update, command.Update, useCase.Description)
+		assert.EqualValues(t, useCase.Expected.Documents, command.Documents, useCase.Description)
+		assert.EqualValues(t, useCase.Expected.Columns, command.Columns, useCase.Description)
+		assert.EqualValues(t, useCase.Expected.Explain, command.Explain, useCase.Description)
+		assert.EqualValues(t, useCase.Expected.Pipeline, command.Pipeline, useCase.Description)
+	}
+}
+
+func TestCommand_MarshalJSON(t *testing.T) {
+	var useCases = []struct {
+		Description string
+		SQL         string
+		Parameters  []interface{}
+		Expected    string
+	}{
+		{
+			Description: "find with ObjectId literal",
+			SQL:         "SELECT id, name FROM users WHERE _id = ObjectId(?) ORDER BY name DESC LIMIT 10",
+			Parameters:  []interface{}{"5b2d6f6e1d41c8f1a8f3a1b2"},
+			Expected:    `{"type": "find", "collection": "users", "filter": {"_id": {"$eq": {"$oid": "5b2d6f6e1d41c8f1a8f3a1b2"}}}, "projection": {"id": 1, "name": 1, "_id": 0}, "sort": ["-name"], "limit": 10}`,
+		},
+		{
+			Description: "native pipeline keeps fields order",
+			SQL:         "SELECT * FROM users WHERE $json(?) ORDER BY name LIMIT 5",
+			Parameters:  []interface{}{`[{"$sort": {"b": 1, "a": -1}}]`},
+			Expected:    `{"type": "aggregate", "collection": "users", "pipeline": [{"$sort": {"b": 1, "a": -1}}, {"$sort": {"name": 1}}, {"$limit": 5}]}`,
+		},
+	}
+	for _, useCase := range useCases {
+		command, err := mgc.Translate(useCase.SQL, useCase.Parameters)
+		if !assert.Nil(t, err, useCase.Description) {
+			continue
+		}
+		data, err := json.Marshal(command)
+		if !assert.Nil(t, err, useCase.Description) {
+			continue
+		}
+		assert.JSONEq(t, useCase.Expected, string(data), useCase.Description)
+	}
+	command, err := mgc.Translate("SELECT * FROM users WHERE $json(?)", []interface{}{`[{"$sort": {"b": 1, "a": -1}}]`})
+	if assert.Nil(t, err) {
+		data, err := json.Marshal(command)
+		if assert.Nil(t, err) {
+			assert.Contains(t, string(data), `{"$sort":{"b":1,"a":-1}}`, "ordered document should be encoded as JSON object")
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package mgc
+
+import (
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"regexp"
+	"strings"
+)
+
+var updateSetExpr = regexp.MustCompile(`(?is)^\s*UPDATE\s+([\w.]+)\s+SET\s+`)
+var arithmeticAssignmentExpr = regexp.MustCompile(`(?is)^\s*([\w.]+)\s*=\s*([\w.]+)\s*([-+*])\s*(.+?)\s*$`)
+
+//updateExpressions represents UPDATE arithmetic assignments, i.e. SET hits = hits + ?
+type updateExpressions struct {
+	Table string
+	Where string //WHERE clause, including WHERE keyword
+	Set   string //remaining plain assignments
+	Inc   map[string]interface{}
+	Mul   map[string]interface{}
+}
+
+//PlainSQL returns UPDATE SQL with plain assignments only, or empty string if there are none
+func (e *updateExpressions) PlainSQL() string {
+	if strings.TrimSpace(e.Set) == "" {
+		return ""
+	}
+	return strings.TrimSpace("UPDATE " + e.Table + " SET " + e.Set + " " + e.Where)
+}
+
+//negateNumber returns negated numeric value
+func negateNumber(value interface{}) (interface{}, error) {
+	switch actual := value.(type) {
+	case int:
+		return -actual, nil
+	case int32:
+		return -actual, nil
+	case int64:
+		return -actual, nil
+	case float32:
+		return -actual, nil
+	case float64:
+		return -actual, nil
+	}
+	return nil, fmt.Errorf("expected numeric value, but had %T", value)
+}
+
+//extractUpdateExpressions returns UPDATE arithmetic assignments and parameters of remaining statement, or nil if SQL has no arithmetic assignments
+func extractUpdateExpressions(SQL string, parameters []interface{}) (*updateExpressions, []interface{}, error) {
+	location := updateSetExpr.FindStringSubmatchIndex(SQL)
+	if location == nil {
+		return nil, parameters, nil
+	}
+	var result = &updateExpressions{
+		Table: SQL[location[2]:location[3]],
+		Inc:   make(map[string]interface{}),
+		Mul:   make(map[string]interface{}),
+	}
+	var end = len(SQL)
+	if index := indexKeyword(SQL, "WHERE"); index > location[1] {
+		end = index
+	}
+	result.Where = SQL[end:]
+	var plain = make([]string, 0)
+	var plainParameters = make([]interface{}, 0)
+	var paramIndex = 0
+	for _, assignment := range splitTopLevel(SQL[location[1]:end], ',') {
+		matched := arithmeticAssignmentExpr.FindStringSubmatch(assignment)
+		if len(matched) == 0 || matched[1] != matched[2] {
+			var count = countPlaceholders(assignment)
+			if paramIndex+count > len(parameters) {
+				return nil, nil, fmt.Errorf("array out of bound, %v %v", paramIndex+count, len(parameters))
+			}
+			plain = append(plain, strings.TrimSpace(assignment))
+			plainParameters = append(plainParameters, parameters[paramIndex:paramIndex+count]...)
+			paramIndex += count
+			continue
+		}
+		value, err := MapValue(matched[4], parameters, &paramIndex)
+		if err != nil {
+			return nil, nil, err
+		}
+		switch matched[3] {
+		case "+":
+			result.Inc[matched[1]] = value
+		case "-":
+			if result.Inc[matched[1]], err = negateNumber(value); err != nil {
+				return nil, nil, fmt.Errorf("invalid %v decrement, %v", matched[1], err)
+			}
+		case "*":
+			result.Mul[matched[1]] = value
+		}
+	}
+	if len(result.Inc) == 0 && len(result.Mul) == 0 {
+		return nil, parameters, nil
+	}
+	result.Set = strings.Join(plain, ", ")
+	return result, append(plainParameters, parameters[paramIndex:]...), nil
+}
+
+//asUpdateDocument returns update document with $set for assigned columns, $inc and $mul for arithmetic assignments
+func asUpdateDocument(record map[string]interface{}, expressions *updateExpressions) bson.M {
+	var result = bson.M{}
+	if len(record) > 0 {
+		result["$set"] = record
+	}
+	if expressions != nil {
+		if len(expressions.Inc) > 0 {
+			result["$inc"] = expressions.Inc
+		}
+		if len(expressions.Mul) > 0 {
+			result["$mul"] = expressions.Mul
+		}
+	}
+	return result
+}